@@ -0,0 +1,120 @@
+// Command kademlia controls a running node through its control socket.
+//
+//	kademlia [-socket path] put [file]   store a file, or stdin, and print its hash
+//	kademlia [-socket path] get <hash>   write the value with the hash to stdout
+//	kademlia [-socket path] ping <ip:port>
+//	kademlia [-socket path] lookup <id>  print the closest contacts to the id
+//	kademlia [-socket path] routes       print the routing table and the number of
+//	                                     malformed messages received
+//	kademlia [-socket path] exit         stop the node
+//
+// Exit codes: 0 success, 1 error, 2 usage, 3 value not found.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	dht "github.com/ojaebe-6/D7024E/kademlia"
+)
+
+const (
+	exitSuccess = 0
+	exitError = 1
+	exitUsage = 2
+	exitNotFound = 3
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kademlia [-socket path] put [file] | get <hash> | ping <ip:port> | lookup <id> | routes | exit")
+	flag.PrintDefaults()
+}
+
+func main() {
+	socket := flag.String("socket", dht.DefaultControlSocket, "control socket of the node")
+	flag.Usage = usage
+	flag.Parse()
+	os.Exit(run(*socket, flag.Args()))
+}
+
+func run(socket string, args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	var value io.Reader
+	argument := ""
+	switch args[0] {
+	case "put":
+		if len(args) > 2 {
+			usage()
+			return exitUsage
+		}
+		value = os.Stdin
+		if len(args) == 2 && args[1] != "-" {
+			file, error := os.Open(args[1])
+			if error != nil {
+				fmt.Fprintln(os.Stderr, error)
+				return exitError
+			}
+			defer file.Close()
+			value = file
+		}
+	case "get", "ping", "lookup":
+		if len(args) != 2 {
+			usage()
+			return exitUsage
+		}
+		argument = args[1]
+	case "routes", "exit":
+		if len(args) != 1 {
+			usage()
+			return exitUsage
+		}
+	default:
+		usage()
+		return exitUsage
+	}
+
+	connection, error := net.Dial("unix", socket)
+	if error != nil {
+		fmt.Fprintln(os.Stderr, "could not connect to node:", error)
+		return exitError
+	}
+	defer connection.Close()
+
+	fmt.Fprintf(connection, "%s %s\n", args[0], argument)
+	if value != nil {
+		if _, error := io.Copy(connection, value); error != nil {
+			fmt.Fprintln(os.Stderr, error)
+			return exitError
+		}
+	}
+	connection.(*net.UnixConn).CloseWrite()
+
+	reader := bufio.NewReader(connection)
+	status, error := reader.ReadString('\n')
+	if error != nil {
+		fmt.Fprintln(os.Stderr, "no response from node:", error)
+		return exitError
+	}
+	status = strings.TrimSpace(status)
+
+	switch {
+	case status == "ok":
+		io.Copy(os.Stdout, reader)
+		return exitSuccess
+	case status == "notfound":
+		fmt.Fprintln(os.Stderr, "not found")
+		return exitNotFound
+	default:
+		fmt.Fprintln(os.Stderr, strings.TrimPrefix(status, "error "))
+		return exitError
+	}
+}
@@ -0,0 +1,320 @@
+// Command kademliad runs a Kademlia node with an interactive menu, or in the
+// background with -daemon, and bootstraps from the nodes given as arguments.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	dht "github.com/ojaebe-6/D7024E/kademlia"
+)
+
+func bootstrap(ctx context.Context, node *dht.Node, nodeAddresses []string) {
+	//Revalidate contacts saved by the previous run
+	savedAnswered := node.PingSavedContacts(ctx)
+	if savedAnswered > 0 {
+		fmt.Println(strconv.Itoa(savedAnswered) + " saved contacts answered");
+	}
+
+	//Bootstrap from the saved contacts if no bootstrap nodes are given
+	successfulPing := len(nodeAddresses) == 0 && savedAnswered > 0
+	for _, nodeAddress := range nodeAddresses {
+		contact := dht.ParseNodeAddress(nodeAddress)
+		if contact != nil {
+			fmt.Println("Pinging bootstrap node " + contact.UDPAddress().String())
+			error := node.Ping(ctx, contact)
+
+			if error == nil {
+				fmt.Println("Ping successful!");
+				successfulPing = true
+			} else {
+				fmt.Println("Ping failed: " + error.Error());
+			}
+		}
+	}
+
+	if !successfulPing {
+		fmt.Println("No bootstrap nodes answered. Bootstrap failed");
+	} else {
+		//Lookup my ID and add contacts to k-buckets
+		node.Bootstrap(ctx)
+
+		//Successful bootstrap
+		fmt.Println("Bootstrap successful!");
+	}
+}
+
+//Serves control requests in the background, returns nil if the socket can not be used
+func startControlServer(node *dht.Node, path string) *dht.ControlServer {
+	server, error := dht.NewControlServer(node, path)
+	if error != nil {
+		log.Println(error)
+		return nil
+	}
+	fmt.Println("Listening for control requests on " + path)
+	go server.Serve()
+	return server
+}
+
+func main() {
+	listenAddress := flag.String("listen", ":" + strconv.Itoa(dht.StandardPort), "UDP address to listen on")
+	httpAddress := flag.String("http", "", "TCP address to serve the HTTP object API on, disabled if empty")
+	controlSocket := flag.String("control", dht.DefaultControlSocket, "unix socket for the kademlia command, disabled if empty")
+	daemon := flag.Bool("daemon", false, "run without the interactive menu")
+	dataDirectory := flag.String("data", "", "directory keeping the node ID, values and contacts across restarts, values are kept in memory if empty")
+	maxBytes := flag.Int64("max-bytes", 0, "most bytes of values stored at this node, unlimited if 0")
+	maxValues := flag.Int("max-values", 0, "most values stored at this node, unlimited if 0")
+	eviction := flag.String("eviction", "none", "values removed to make room when full: none, lru or farthest")
+	flag.Parse()
+
+	policy, error := dht.ParseEvictionPolicy(*eviction)
+	if error != nil {
+		log.Fatal(error)
+	}
+
+	//Stop the node gracefully on interrupt
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	node, error := dht.NewNode(*listenAddress, *dataDirectory)
+	if error != nil {
+		log.Fatal(error)
+	}
+	node.Kademlia().SetQuota(dht.Quota{MaxBytes:*maxBytes, MaxValues:*maxValues, Policy:policy})
+	if error := node.Start(ctx); error != nil {
+		log.Fatal(error)
+	}
+	kademlia, network := node.Kademlia(), node.Network()
+
+	fmt.Println("Node " + kademlia.ID().String() + " initalized on " + *listenAddress + "!");
+
+	//Remaining arguments are bootstrap nodes as "ip:port" or "ip", saved contacts are used if there are none
+	bootstrap(ctx, node, flag.Args())
+
+	if *httpAddress != "" {
+		go func() {
+			fmt.Println("Serving HTTP object API on " + *httpAddress)
+			log.Println(http.ListenAndServe(*httpAddress, dht.NewHTTPHandler(node)))
+		}()
+	}
+
+	if *controlSocket != "" {
+		if server := startControlServer(node, *controlSocket); server != nil {
+			defer server.Close()
+		}
+	}
+
+	if !*daemon {
+		go func() {
+			CmdInterface(kademlia, network);
+			node.Stop()
+		}()
+	}
+
+	//Run until stopped with the exit command or a signal
+	<-node.Done()
+	fmt.Println("Node stopped");
+}
+
+func Put(content string, kademlia *dht.Kademlia, network *dht.Network) [20]byte{
+	var hash [20]byte;
+	dataToSend := []byte(content);
+	// Returned Hash value stores in byte slice.
+	hash = dht.StoreData(kademlia, network, dataToSend, dht.ReplicationFactor);
+	fmt.Println(hex.EncodeToString(hash[:]));
+	return hash;
+}
+
+func Get(convertHash string , kademlia *dht.Kademlia, network *dht.Network, isLocal bool){
+	var outStr []byte;
+	var outFixed [20]byte;
+	if(len(convertHash) == 40){
+		outData, err := hex.DecodeString(convertHash);
+		if err != nil {
+			fmt.Println("Failed to get data.");
+		}
+		copy(outFixed[0:20], outData[:]);
+		if(isLocal){
+			outStr = kademlia.LookupData(outFixed);
+
+		}else{
+			outStr = dht.LookupData(kademlia, network, outFixed);
+		}
+		if(outStr == nil){
+			fmt.Println("Empty Data.");
+		} else{
+			fmt.Println(string(outStr));
+		}
+		// fmt.Println("%convert\n", outData);
+	} else{
+		fmt.Println("hex string is not 40 in length.");
+	}
+}
+
+func Forget(convertHash string, kademlia *dht.Kademlia){
+	var outFixed [20]byte;
+	outData, err := hex.DecodeString(convertHash);
+	if(err != nil || len(outData) != 20){
+		fmt.Println("hex string is not 40 in length.");
+		return;
+	}
+	copy(outFixed[0:20], outData[:]);
+	if(kademlia.Forget(outFixed)){
+		fmt.Println("Data will no longer be refreshed.");
+	} else{
+		fmt.Println("Data was not published by this node.");
+	}
+}
+
+func ForgetFile(convertHash string, kademlia *dht.Kademlia, network *dht.Network){
+	var outFixed [20]byte;
+	outData, err := hex.DecodeString(convertHash);
+	if(err != nil || len(outData) != 20){
+		fmt.Println("hex string is not 40 in length.");
+		return;
+	}
+	copy(outFixed[0:20], outData[:]);
+	if(dht.ForgetFile(kademlia, network, outFixed)){
+		fmt.Println("File will no longer be refreshed.");
+	} else{
+		fmt.Println("File was not published by this node.");
+	}
+}
+
+func PutFile(path string, kademlia *dht.Kademlia, network *dht.Network){
+	data, err := os.ReadFile(path);
+	if(err != nil){
+		fmt.Println("Failed to read file.");
+		return;
+	}
+	hash, err := dht.StoreFile(kademlia, network, data);
+	if(err != nil){
+		fmt.Println("Failed to store file: " + err.Error());
+		return;
+	}
+	fmt.Println(hex.EncodeToString(hash[:]));
+}
+
+func GetFile(convertHash string, path string, kademlia *dht.Kademlia, network *dht.Network){
+	var outFixed [20]byte;
+	outData, err := hex.DecodeString(convertHash);
+	if(err != nil || len(outData) != 20){
+		fmt.Println("hex string is not 40 in length.");
+		return;
+	}
+	copy(outFixed[0:20], outData[:]);
+	data, err := dht.FetchFile(kademlia, network, outFixed);
+	if(err != nil){
+		fmt.Println("Failed to get file: " + err.Error());
+		return;
+	}
+	if(os.WriteFile(path, data, 0644) != nil){
+		fmt.Println("Failed to write file.");
+		return;
+	}
+	fmt.Println("Wrote " + strconv.Itoa(len(data)) + " bytes.");
+}
+
+func CmdInterface(kademlia *dht.Kademlia, network *dht.Network) {
+	var in string;
+	lines := "----------------------------------------";
+	var textIn string;
+	var pathIn string;
+
+	for (in != "exit") {
+		fmt.Println(lines);
+		fmt.Println("| Node: TEST.\t       |");
+		fmt.Println(lines);
+		fmt.Println("| Options: put | get | getlocal | forget | putfile | getfile | forgetfile | exit |");
+		fmt.Println(lines);
+		fmt.Print("| Option: ");
+		fmt.Scanln(&in);
+		switch in {
+		case "put":
+			// Store to byte array
+			fmt.Println();
+			fmt.Print("| Add data to be stored: ");
+			fmt.Scanln(&textIn);
+			// because golang just simply works like this, we assign string input to a
+			// byte slice, as for replication Factor, no idea how many copies we want to store.
+			// As for where to use this function, beats me.
+			fmt.Print("| Hash: ")
+			Put(textIn, kademlia, network);
+			fmt.Println();
+			continue;
+		case "get":
+			fmt.Print("| Hash for data: ");
+			fmt.Scanln(&textIn);
+			{
+				fmt.Print("| Output: ")
+				Get(textIn, kademlia, network, false);
+				fmt.Println();
+			}
+			continue;
+
+		case "getlocal":
+			fmt.Print("| Hash for local data: ");
+			fmt.Scanln(&textIn);
+			{
+				fmt.Print("| Output: ")
+				Get(textIn, kademlia, network, true);
+				fmt.Println();
+			}
+			continue;
+
+		case "forget":
+			fmt.Print("| Hash for data to forget: ");
+			fmt.Scanln(&textIn);
+			{
+				fmt.Print("| Output: ")
+				Forget(textIn, kademlia);
+				fmt.Println();
+			}
+			continue;
+
+		case "putfile":
+			fmt.Print("| Path of file to store: ");
+			fmt.Scanln(&pathIn);
+			{
+				fmt.Print("| Hash: ")
+				PutFile(pathIn, kademlia, network);
+				fmt.Println();
+			}
+			continue;
+
+		case "getfile":
+			fmt.Print("| Hash for file: ");
+			fmt.Scanln(&textIn);
+			fmt.Print("| Path to write file to: ");
+			fmt.Scanln(&pathIn);
+			{
+				fmt.Print("| Output: ")
+				GetFile(textIn, pathIn, kademlia, network);
+				fmt.Println();
+			}
+			continue;
+
+		case "forgetfile":
+			fmt.Print("| Hash for file to forget: ");
+			fmt.Scanln(&textIn);
+			{
+				fmt.Print("| Output: ")
+				ForgetFile(textIn, kademlia, network);
+				fmt.Println();
+			}
+			continue;
+
+		case "exit":
+			fmt.Println("Exit");
+			continue;
+		}
+	}
+}
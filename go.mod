@@ -0,0 +1,3 @@
+module github.com/ojaebe-6/D7024E
+
+go 1.20
@@ -0,0 +1,115 @@
+package kademlia
+
+import (
+	"container/list"
+	"time"
+)
+
+// bucket definition
+// contains a List, a List of replacement Contacts
+// that did not fit in the full bucket and the time of
+// the last lookup in the range of the bucket
+type bucket struct {
+	list *list.List
+	replacements *list.List
+	lastUsed time.Time
+}
+
+// newBucket returns a new instance of a bucket
+func newBucket() *bucket {
+	bucket := &bucket{}
+	bucket.list = list.New()
+	bucket.replacements = list.New()
+	bucket.lastUsed = time.Now()
+	return bucket
+}
+
+// AddContact adds the Contact to the front of the bucket
+// or moves it to the front of the bucket if it already existed.
+// If the bucket is full the contact is added to the replacement cache
+// and the least recently seen Contact is returned, otherwise nil is returned
+func (bucket *bucket) AddContact(contact Contact) *Contact {
+	var element *list.Element
+	for e := bucket.list.Front(); e != nil; e = e.Next() {
+		nodeID := e.Value.(Contact).ID
+
+		if (contact).ID.Equals(nodeID) {
+			element = e
+		}
+	}
+
+	if element == nil {
+		if bucket.list.Len() < bucketSize {
+			bucket.list.PushFront(contact)
+		} else {
+			bucket.addReplacement(contact)
+			leastRecentlySeen := bucket.list.Back().Value.(Contact)
+			return &leastRecentlySeen
+		}
+	} else {
+		bucket.list.MoveToFront(element)
+	}
+	return nil
+}
+
+// RemoveContact removes the Contact with the given ID from the bucket,
+// promotes the most recently seen replacement and returns true if it existed
+func (bucket *bucket) RemoveContact(id *KademliaID) bool {
+	for e := bucket.list.Front(); e != nil; e = e.Next() {
+		if id.Equals(e.Value.(Contact).ID) {
+			bucket.list.Remove(e)
+
+			if replacement := bucket.replacements.Front(); replacement != nil {
+				bucket.replacements.Remove(replacement)
+				bucket.list.PushBack(replacement.Value.(Contact))
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// HasContact returns true if the Contact with the given ID is in the bucket
+func (bucket *bucket) HasContact(id *KademliaID) bool {
+	for e := bucket.list.Front(); e != nil; e = e.Next() {
+		if id.Equals(e.Value.(Contact).ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// addReplacement adds the Contact to the front of the replacement cache,
+// dropping the least recently seen replacement if the cache is full
+func (bucket *bucket) addReplacement(contact Contact) {
+	for e := bucket.replacements.Front(); e != nil; e = e.Next() {
+		if contact.ID.Equals(e.Value.(Contact).ID) {
+			bucket.replacements.Remove(e)
+			break
+		}
+	}
+
+	bucket.replacements.PushFront(contact)
+	if bucket.replacements.Len() > replacementCacheSize {
+		bucket.replacements.Remove(bucket.replacements.Back())
+	}
+}
+
+// GetContactAndCalcDistance returns an array of Contacts where
+// the distance has already been calculated
+func (bucket *bucket) GetContactAndCalcDistance(target *KademliaID) []Contact {
+	var contacts []Contact
+
+	for elt := bucket.list.Front(); elt != nil; elt = elt.Next() {
+		contact := elt.Value.(Contact)
+		contact.CalcDistance(target)
+		contacts = append(contacts, contact)
+	}
+
+	return contacts
+}
+
+// Len return the size of the bucket
+func (bucket *bucket) Len() int {
+	return bucket.list.Len()
+}
@@ -1,22 +1,54 @@
-package main
+package kademlia
 
 import (
 	"fmt"
 	"net"
 	"sort"
+	"strconv"
 )
 
 // Contact definition
-// stores the KademliaID, the ip address and the distance
+// stores the KademliaID, the ip address, the UDP port and the distance
 type Contact struct {
 	ID       *KademliaID
 	Address  net.IP
+	Port     int
 	distance *KademliaID
 }
 
-// NewContact returns a new instance of a Contact
+// NewContact returns a new instance of a Contact on the standard port
 func NewContact(id *KademliaID, address net.IP) Contact {
-	return Contact{id, address, nil}
+	return NewContactWithPort(id, address, StandardPort)
+}
+
+// NewContactWithPort returns a new instance of a Contact on the given port
+func NewContactWithPort(id *KademliaID, address net.IP, port int) Contact {
+	return Contact{id, address, port, nil}
+}
+
+// UDPAddress returns the UDP address of the Contact
+func (contact *Contact) UDPAddress() *net.UDPAddr {
+	return &net.UDPAddr{IP: contact.Address, Port: contact.Port}
+}
+
+// ParseNodeAddress returns a contact without ID for a node address given as
+// "ip:port" or "ip", or nil if the address is invalid
+func ParseNodeAddress(nodeAddress string) *Contact {
+	host, portString, error := net.SplitHostPort(nodeAddress)
+	if error != nil {
+		//No port given
+		host = nodeAddress
+		portString = strconv.Itoa(StandardPort)
+	}
+
+	ip := net.ParseIP(host)
+	port, error := strconv.Atoi(portString)
+	if ip == nil || error != nil || port <= 0 || port > 65535 {
+		return nil
+	}
+
+	contact := NewContactWithPort(nil, ip, port)
+	return &contact
 }
 
 // CalcDistance calculates the distance to the target and
@@ -0,0 +1,163 @@
+package kademlia
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Default path of the control socket used by the kademlia command
+var DefaultControlSocket = filepath.Join(os.TempDir(), "kademlia.sock")
+
+// Control protocol, one request per connection:
+//   request:  "<command> [argument]\n" followed by the value for put,
+//             the client closes its writing side when done
+//   response: "ok\n", "notfound\n" or "error <message>\n" followed by
+//             the result, the daemon closes the connection when done
+const (
+	controlOK = "ok"
+	controlNotFound = "notfound"
+	controlError = "error"
+)
+
+// ControlServer definition
+// answers requests from the kademlia command on a unix socket
+type ControlServer struct {
+	node *Node
+	listener net.Listener
+}
+
+// NewControlServer listens for control requests on the unix socket path,
+// replacing a stale socket left behind by a previous daemon
+func NewControlServer(node *Node, path string) (*ControlServer, error) {
+	if connection, error := net.Dial("unix", path); error == nil {
+		connection.Close()
+		return nil, fmt.Errorf("control socket %s is in use by another node", path)
+	}
+	os.Remove(path)
+
+	listener, error := net.Listen("unix", path)
+	if error != nil {
+		return nil, error
+	}
+	return &ControlServer{node:node, listener:listener}, nil
+}
+
+// Serve answers control requests until the server is closed
+func (server *ControlServer) Serve() {
+	for {
+		connection, error := server.listener.Accept()
+		if error != nil {
+			return
+		}
+		go server.handleConnection(connection)
+	}
+}
+
+// Close stops the server and removes the socket
+func (server *ControlServer) Close() error {
+	return server.listener.Close()
+}
+
+func (server *ControlServer) handleConnection(connection net.Conn) {
+	defer connection.Close()
+	reader := bufio.NewReader(connection)
+
+	line, error := reader.ReadString('\n')
+	if error != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		fmt.Fprintf(connection, "%s empty request\n", controlError)
+		return
+	}
+
+	argument := ""
+	if len(fields) > 1 {
+		argument = fields[1]
+	}
+
+	switch fields[0] {
+	case "put":
+		data, error := io.ReadAll(io.LimitReader(reader, httpMaxObjectSize + 1))
+		if error != nil || len(data) > httpMaxObjectSize {
+			fmt.Fprintf(connection, "%s value too large\n", controlError)
+			return
+		}
+		hash, error := server.node.StoreData(context.Background(), data)
+		if error != nil {
+			fmt.Fprintf(connection, "%s %s\n", controlError, error.Error())
+			return
+		}
+		fmt.Fprintf(connection, "%s\n%s\n", controlOK, hex.EncodeToString(hash[:]))
+
+	case "get":
+		var hash [20]byte
+		decoded, error := hex.DecodeString(argument)
+		if error != nil || len(decoded) != len(hash) {
+			fmt.Fprintf(connection, "%s hash must be 40 hexadecimal characters\n", controlError)
+			return
+		}
+		copy(hash[:], decoded)
+		data, error := server.node.LookupData(context.Background(), hash)
+		if error != nil {
+			fmt.Fprintf(connection, "%s %s\n", controlError, error.Error())
+			return
+		}
+		if data == nil {
+			fmt.Fprintf(connection, "%s\n", controlNotFound)
+			return
+		}
+		fmt.Fprintf(connection, "%s\n", controlOK)
+		connection.Write(data)
+
+	case "ping":
+		contact := ParseNodeAddress(argument)
+		if contact == nil {
+			fmt.Fprintf(connection, "%s address must be ip:port or ip\n", controlError)
+			return
+		}
+		if error := server.node.Ping(context.Background(), contact); error != nil {
+			fmt.Fprintf(connection, "%s no answer from %s: %v\n", controlError, contact.UDPAddress().String(), error)
+			return
+		}
+		fmt.Fprintf(connection, "%s\n", controlOK)
+
+	case "lookup":
+		decoded, error := hex.DecodeString(argument)
+		if error != nil || len(decoded) != IDLength {
+			fmt.Fprintf(connection, "%s id must be 40 hexadecimal characters\n", controlError)
+			return
+		}
+		contacts := server.node.LookupContact(context.Background(), NewKademliaIDFromBytes(decoded), bucketSize)
+		fmt.Fprintf(connection, "%s\n", controlOK)
+		writeContacts(connection, contacts)
+
+	case "routes":
+		fmt.Fprintf(connection, "%s\n", controlOK)
+		writeContacts(connection, server.node.kademlia.GetContacts())
+		fmt.Fprintf(connection, "malformed %d\n", server.node.network.MalformedMessages())
+
+	case "exit":
+		fmt.Fprintf(connection, "%s\n", controlOK)
+		connection.Close()
+		server.node.Stop()
+
+	default:
+		fmt.Fprintf(connection, "%s unknown command %s\n", controlError, fields[0])
+	}
+}
+
+//Writes one contact per line as ID and address
+func writeContacts(writer io.Writer, contacts []Contact) {
+	for _, contact := range contacts {
+		fmt.Fprintf(writer, "%s %s\n", contact.ID.String(), contact.UDPAddress().String())
+	}
+}
@@ -0,0 +1,86 @@
+package kademlia
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// controlRequest sends a control request and returns the status line and result
+func controlRequest(t *testing.T, path string, request string, value string) (string, string) {
+	connection, error := net.Dial("unix", path)
+	if error != nil {
+		t.Fatal(error)
+	}
+	defer connection.Close()
+
+	io.WriteString(connection, request + "\n" + value)
+	connection.(*net.UnixConn).CloseWrite()
+
+	reader := bufio.NewReader(connection)
+	status, _ := reader.ReadString('\n')
+	result, _ := io.ReadAll(reader)
+	return strings.TrimSpace(status), string(result)
+}
+
+func TestControlServer(t *testing.T) {
+	nodes := newMemoryNodes(t, 5)
+	path := filepath.Join(t.TempDir(), "kademlia.sock")
+
+	server, error := NewControlServer(nodes[1], path)
+	if error != nil {
+		t.Fatal(error)
+	}
+	defer server.Close()
+	go server.Serve()
+
+	if _, error := NewControlServer(nodes[2], path); error == nil {
+		t.Error("Started two control servers on the same socket")
+	}
+
+	status, hash := controlRequest(t, path, "put", "Hello world")
+	hash = strings.TrimSpace(hash)
+	if status != controlOK || hash != "7b502c3a1f48c8609ae212cdfb639dee39673f5e" {
+		t.Error("Incorrect put response (" + status + " " + hash + ")")
+	}
+
+	status, result := controlRequest(t, path, "get " + hash, "")
+	if status != controlOK || result != "Hello world" {
+		t.Error("Incorrect get response (" + status + " " + result + ")")
+	}
+
+	if status, _ := controlRequest(t, path, "get 0000000000000000000000000000000000000000", ""); status != controlNotFound {
+		t.Error("Found missing value (" + status + ")")
+	}
+	if status, _ := controlRequest(t, path, "get hej", ""); !strings.HasPrefix(status, controlError) {
+		t.Error("Accepted malformed hash (" + status + ")")
+	}
+	if status, _ := controlRequest(t, path, "ping 10.0.0.0", ""); status != controlOK {
+		t.Error("Failed to ping (" + status + ")")
+	}
+	if status, result := controlRequest(t, path, "routes", ""); status != controlOK || len(strings.Split(strings.TrimSpace(result), "\n")) < 4 || !strings.Contains(result, "malformed 0\n") {
+		t.Error("Incorrect routes response (" + status + " " + result + ")")
+	}
+	if status, result := controlRequest(t, path, "lookup " + nodes[3].kademlia.myID.String(), ""); status != controlOK || !strings.HasPrefix(result, nodes[3].kademlia.myID.String()) {
+		t.Error("Incorrect lookup response (" + status + " " + result + ")")
+	}
+	if status, _ := controlRequest(t, path, "hej", ""); !strings.HasPrefix(status, controlError) {
+		t.Error("Accepted unknown command (" + status + ")")
+	}
+
+	if status, _ := controlRequest(t, path, "exit", ""); status != controlOK {
+		t.Error("Failed to exit (" + status + ")")
+	}
+	select {
+	case <-nodes[1].Done():
+	case <-time.After(time.Second):
+		t.Error("Node was not stopped")
+	}
+	if status, _ := controlRequest(t, path, "put", "Hello world"); !strings.HasPrefix(status, controlError) {
+		t.Error("Stored on a stopped node (" + status + ")")
+	}
+}
@@ -0,0 +1,203 @@
+package kademlia
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Suffix of files being written, left behind if the node crashes while writing
+const temporaryFileSuffix = ".tmp"
+
+// diskStorage definition
+// keeps every value in a file named by its hash in a directory, holding the
+// expiration time in Unix nanoseconds followed by the data, and an index of
+// the expiration times and sizes so that they are read from disk only once
+type diskStorage struct {
+	directory string
+	//Held while changing files and the index, reading files does not need it
+	mutex sync.RWMutex
+	index map[[20]byte]diskEntry
+}
+
+type diskEntry struct {
+	expiration time.Time
+	size int
+}
+
+// NewDiskStorage returns a Storage that keeps values in files in the directory,
+// creating it if needed. Values stored before are kept
+func NewDiskStorage(directory string) (Storage, error) {
+	if error := os.MkdirAll(directory, 0755); error != nil {
+		return nil, error
+	}
+
+	//Remove values that were being written when the node crashed
+	entries, error := os.ReadDir(directory)
+	if error != nil {
+		return nil, error
+	}
+	storage := &diskStorage{directory:directory, index:make(map[[20]byte]diskEntry, len(entries))}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), temporaryFileSuffix) {
+			os.Remove(filepath.Join(directory, entry.Name()))
+			continue
+		}
+		storage.indexFile(entry)
+	}
+
+	return storage, nil
+}
+
+//Adds the value in the file to the index by reading its expiration time
+func (storage *diskStorage) indexFile(entry os.DirEntry) {
+	var hash [20]byte
+	decoded, error := hex.DecodeString(entry.Name())
+	if error != nil || len(decoded) != len(hash) {
+		return
+	}
+	copy(hash[:], decoded)
+
+	info, error := entry.Info()
+	if error != nil {
+		return
+	}
+	file, error := os.Open(filepath.Join(storage.directory, entry.Name()))
+	if error != nil {
+		return
+	}
+	header := make([]byte, 8)
+	_, error = io.ReadFull(file, header)
+	file.Close()
+	if error == nil {
+		storage.index[hash] = diskEntry{expiration:time.Unix(0, int64(binary.LittleEndian.Uint64(header))), size:int(info.Size()) - 8}
+	}
+}
+
+func (storage *diskStorage) path(hash [20]byte) string {
+	return filepath.Join(storage.directory, hex.EncodeToString(hash[:]))
+}
+
+func (storage *diskStorage) Get(hash [20]byte) ([]byte, time.Time, error) {
+	content, error := os.ReadFile(storage.path(hash))
+	if errors.Is(error, os.ErrNotExist) {
+		return nil, time.Time{}, nil
+	}
+	if error != nil {
+		return nil, time.Time{}, error
+	}
+
+	//Values are replaced by renaming, so a damaged file means the disk is corrupted
+	if !validContent(hash, content) {
+		storage.removeCorrupted(hash)
+		return nil, time.Time{}, nil
+	}
+	return content[8:], time.Unix(0, int64(binary.LittleEndian.Uint64(content[:8]))), nil
+}
+
+//Returns true if the file content holds an expiration time and the data with the hash
+func validContent(hash [20]byte, content []byte) bool {
+	return len(content) >= 8 && sha1.Sum(content[8:]) == hash
+}
+
+//Removes a corrupted value unless it was replaced since it was read, the file is
+//read again with the lock held so that a concurrent Put is not undone
+func (storage *diskStorage) removeCorrupted(hash [20]byte) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	content, error := os.ReadFile(storage.path(hash))
+	if error != nil || validContent(hash, content) {
+		return
+	}
+	log.Println("Removing corrupted value " + hex.EncodeToString(hash[:]))
+	if os.Remove(storage.path(hash)) == nil {
+		delete(storage.index, hash)
+	}
+}
+
+func (storage *diskStorage) Put(hash [20]byte, data []byte, expiration time.Time) error {
+	content := make([]byte, 8 + len(data))
+	binary.LittleEndian.PutUint64(content[:8], uint64(expiration.UnixNano()))
+	copy(content[8:], data)
+
+	//The file and its index entry change together
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if error := writeFileAtomic(storage.path(hash), content); error != nil {
+		return error
+	}
+	storage.index[hash] = diskEntry{expiration:expiration, size:len(data)}
+	return nil
+}
+
+func (storage *diskStorage) Delete(hash [20]byte) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	error := os.Remove(storage.path(hash))
+	if error != nil && !errors.Is(error, os.ErrNotExist) {
+		return error
+	}
+	delete(storage.index, hash)
+	return nil
+}
+
+func (storage *diskStorage) Expirations() (map[[20]byte]time.Time, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	expirations := make(map[[20]byte]time.Time, len(storage.index))
+	for hash, entry := range storage.index {
+		expirations[hash] = entry.expiration
+	}
+	return expirations, nil
+}
+
+func (storage *diskStorage) Sizes() (map[[20]byte]int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	sizes := make(map[[20]byte]int, len(storage.index))
+	for hash, entry := range storage.index {
+		sizes[hash] = entry.size
+	}
+	return sizes, nil
+}
+
+func (storage *diskStorage) Close() error {
+	return nil
+}
+
+//Replaces the file at path with data so that a crash leaves either the old or the new content
+func writeFileAtomic(path string, data []byte) error {
+	file, error := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + "-*" + temporaryFileSuffix)
+	if error != nil {
+		return error
+	}
+	_, error = file.Write(data)
+	if error == nil {
+		error = file.Sync()
+	}
+	if closeError := file.Close(); error == nil {
+		error = closeError
+	}
+	if error == nil {
+		error = os.Rename(file.Name(), path)
+	}
+	if error != nil {
+		os.Remove(file.Name())
+		return error
+	}
+
+	//Make the rename durable
+	if directory, error := os.Open(filepath.Dir(path)); error == nil {
+		directory.Sync()
+		directory.Close()
+	}
+	return nil
+}
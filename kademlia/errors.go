@@ -0,0 +1,63 @@
+package kademlia
+
+import (
+	"errors"
+	"strconv"
+)
+
+//ErrNoResponse is returned when a contact does not answer a request in time
+var ErrNoResponse = errors.New("no response")
+//ErrNoAddress is returned for requests to contacts without an address
+var ErrNoAddress = errors.New("contact has no address")
+//ErrStorageFull is returned when a node refuses to store a value because of its quota
+var ErrStorageFull = errors.New("storage quota exceeded")
+//ErrValueTooLarge is returned when a value is larger than a node accepts
+var ErrValueTooLarge = errors.New("value too large")
+//ErrMalformedRequest is returned when a node can not decode a request
+var ErrMalformedRequest = errors.New("malformed request")
+//ErrUnsupportedVersion is returned when a node does not speak the protocol version of a request
+var ErrUnsupportedVersion = errors.New("unsupported version")
+
+// ErrorCode identifies the reason of an error response
+type ErrorCode byte
+
+const (
+	ErrorMalformedRequest ErrorCode = 1
+	ErrorValueTooLarge ErrorCode = 2
+	ErrorQuotaExceeded ErrorCode = 3
+	ErrorUnsupportedVersion ErrorCode = 4
+)
+
+//Errors matching each code, an unknown code matches none of them
+var errorCodeErrors = map[ErrorCode]error{
+	ErrorMalformedRequest: ErrMalformedRequest,
+	ErrorValueTooLarge: ErrValueTooLarge,
+	ErrorQuotaExceeded: ErrStorageFull,
+	ErrorUnsupportedVersion: ErrUnsupportedVersion,
+}
+
+// RemoteError definition
+// an error response from a contact, errors.Is matches it against the
+// error of its code such as ErrStorageFull
+type RemoteError struct {
+	Code ErrorCode
+	Text string
+}
+
+func (remoteError *RemoteError) Error() string {
+	return "remote error " + strconv.Itoa(int(remoteError.Code)) + ": " + remoteError.Text
+}
+
+func (remoteError *RemoteError) Is(target error) bool {
+	return errorCodeErrors[remoteError.Code] == target
+}
+
+//Returns the code sent in an error response for an error, false if it is not sent to the requester
+func errorCodeOf(error error) (ErrorCode, bool) {
+	for code, codeError := range errorCodeErrors {
+		if errors.Is(error, codeError) {
+			return code, true
+		}
+	}
+	return 0, false
+}
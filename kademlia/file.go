@@ -0,0 +1,181 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+//Size of the chunks files are split into
+const fileChunkSize = 1024 * 1024
+//Number of chunks stored or fetched at the same time
+const fileConcurrency = 8
+//Identifies manifest objects, followed by the file size and the chunk hashes
+var manifestMagic = []byte("KMF1")
+
+//Builds the manifest of a file from its size and chunk hashes
+func encodeManifest(size int, hashes [][20]byte) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.Write(manifestMagic)
+	binary.Write(buffer, binary.LittleEndian, uint64(size))
+	for _, hash := range hashes {
+		buffer.Write(hash[:])
+	}
+	return buffer.Bytes()
+}
+
+//Returns the file size and chunk hashes of a manifest
+func decodeManifest(manifest []byte) (int, [][20]byte, error) {
+	headerLength := len(manifestMagic) + 8
+	if len(manifest) < headerLength || !bytes.Equal(manifest[:len(manifestMagic)], manifestMagic) {
+		return 0, nil, errors.New("not a manifest")
+	}
+
+	size := binary.LittleEndian.Uint64(manifest[len(manifestMagic):headerLength])
+	chunkCount := size / fileChunkSize
+	if size % fileChunkSize != 0 {
+		chunkCount++
+	}
+	if chunkCount > uint64(len(manifest)) || uint64(len(manifest) - headerLength) != chunkCount * 20 {
+		return 0, nil, errors.New("manifest has incorrect length")
+	}
+
+	hashes := make([][20]byte, chunkCount)
+	for i := range hashes {
+		copy(hashes[i][:], manifest[headerLength + i * 20:])
+	}
+	return int(size), hashes, nil
+}
+
+//Splits data into chunks of at most fileChunkSize
+func splitChunks(data []byte) [][]byte {
+	var chunks [][]byte
+	for start := 0; start < len(data); start += fileChunkSize {
+		end := start + fileChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, data[start:end])
+	}
+	return chunks
+}
+
+//StoreFile stores data as chunks and a manifest listing the chunk hashes.
+//Returns the hash of the manifest, which is used to fetch the file, and an
+//error if a chunk or the manifest was not stored at any node. Chunks of a
+//file that was not stored are no longer refreshed
+func StoreFile(kademlia *Kademlia, network *Network, data []byte) ([20]byte, error) {
+	chunks := splitChunks(data)
+	hashes := make([][20]byte, len(chunks))
+
+	//Chunks are stored and answered within the semaphore, bounding the chunks in flight
+	var storeError error
+	var stored [][20]byte
+	mutex := sync.Mutex{}
+	waitGroup := sync.WaitGroup{}
+	semaphore := make(chan struct{}, fileConcurrency)
+	for i := range chunks {
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer waitGroup.Done()
+			hash, error := StoreDataContext(context.Background(), kademlia, network, chunks[i], ReplicationFactor)
+			hashes[i] = hash
+			mutex.Lock()
+			if error != nil {
+				storeError = error
+			} else {
+				stored = append(stored, hash)
+			}
+			mutex.Unlock()
+			<-semaphore
+		}(i)
+	}
+	waitGroup.Wait()
+
+	var manifestHash [20]byte
+	if storeError == nil {
+		manifestHash, storeError = StoreDataContext(context.Background(), kademlia, network, encodeManifest(len(data), hashes), ReplicationFactor)
+	}
+	if storeError != nil {
+		for _, hash := range stored {
+			kademlia.Forget(hash)
+		}
+		return [20]byte{}, storeError
+	}
+	return manifestHash, nil
+}
+
+//FetchFile fetches the manifest and all chunks of a file stored with StoreFile
+//and verifies them against their hashes
+func FetchFile(kademlia *Kademlia, network *Network, manifestHash [20]byte) ([]byte, error) {
+	manifest := LookupData(kademlia, network, manifestHash)
+	if manifest == nil {
+		return nil, errors.New("manifest not found")
+	}
+	if sha1.Sum(manifest) != manifestHash {
+		return nil, errors.New("manifest does not match its hash")
+	}
+
+	size, hashes, decodeError := decodeManifest(manifest)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	chunks := make([][]byte, len(hashes))
+	errorMutex := sync.Mutex{}
+	var chunkError error
+
+	waitGroup := sync.WaitGroup{}
+	semaphore := make(chan struct{}, fileConcurrency)
+	for i := range hashes {
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer waitGroup.Done()
+			chunk := LookupData(kademlia, network, hashes[i])
+			<-semaphore
+
+			errorMutex.Lock()
+			defer errorMutex.Unlock()
+			if chunk == nil {
+				chunkError = errors.New("chunk not found")
+			} else if sha1.Sum(chunk) != hashes[i] {
+				chunkError = errors.New("chunk does not match its hash")
+			} else {
+				chunks[i] = chunk
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if chunkError != nil {
+		return nil, chunkError
+	}
+
+	data := bytes.Join(chunks, nil)
+	if len(data) != size {
+		return nil, errors.New("file has incorrect size")
+	}
+	return data, nil
+}
+
+//ForgetFile stops refreshing the manifest and chunks of a file published by this node.
+//Returns false if the manifest was not published by this node
+func ForgetFile(kademlia *Kademlia, network *Network, manifestHash [20]byte) bool {
+	if !kademlia.getPublished()[manifestHash] {
+		return false
+	}
+
+	manifest := LookupData(kademlia, network, manifestHash)
+
+	if _, hashes, error := decodeManifest(manifest); error == nil {
+		for _, hash := range hashes {
+			kademlia.Forget(hash)
+		}
+	}
+	return kademlia.Forget(manifestHash)
+}
@@ -0,0 +1,68 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	//Manifest
+	hashes := [][20]byte{{1}, {2}, {3}}
+	size, decodedHashes, error := decodeManifest(encodeManifest(2 * fileChunkSize + 1, hashes))
+	if error != nil || size != 2 * fileChunkSize + 1 || len(decodedHashes) != 3 || decodedHashes[2] != hashes[2] {
+		t.Error("Manifest was not decoded correctly")
+	}
+	if _, _, error := decodeManifest(encodeManifest(fileChunkSize + 1, hashes)); error == nil {
+		t.Error("Decoded manifest with incorrect number of chunks")
+	}
+	if _, _, error := decodeManifest([]byte("Hello")); error == nil {
+		t.Error("Decoded data that is not a manifest")
+	}
+
+	if len(splitChunks(make([]byte, 2 * fileChunkSize))) != 2 || len(splitChunks(make([]byte, 2 * fileChunkSize + 1))) != 3 {
+		t.Error("Incorrect number of chunks")
+	}
+
+	//Store and fetch
+	kademlias, networks := newMemoryNetwork(t, 10)
+
+	data := make([]byte, 2 * fileChunkSize + fileChunkSize / 2)
+	rand.Read(data)
+	manifestHash, error := StoreFile(kademlias[1], networks[1], data)
+	if error != nil {
+		t.Error("Failed to store file: " + error.Error())
+	}
+
+	fetched, error := FetchFile(kademlias[2], networks[2], manifestHash)
+	if error != nil {
+		t.Error("Failed to fetch file: " + error.Error())
+	} else if !bytes.Equal(fetched, data) {
+		t.Error("Fetched incorrect file")
+	}
+
+	//Data that is not a manifest
+	hash := StoreData(kademlias[1], networks[1], []byte("Hello"), ReplicationFactor)
+	if _, error := FetchFile(kademlias[1], networks[1], hash); error == nil {
+		t.Error("Fetched data that is not a manifest")
+	}
+
+	//Forget
+	if !ForgetFile(kademlias[1], networks[1], manifestHash) || len(kademlias[1].getPublished()) != 1 {
+		t.Error("File was not forgotten")
+	}
+
+	//Files refused by every node are not stored
+	for _, kademlia := range kademlias {
+		kademlia.SetQuota(Quota{MaxBytes:fileChunkSize / 2})
+	}
+	rand.Read(data)
+	//The last chunk fits the quota of nodes that store nothing else
+	if _, error := StoreFile(kademlias[1], networks[1], data); !errors.Is(error, ErrValueTooLarge) && !errors.Is(error, ErrStorageFull) {
+		t.Error("Stored a file refused by every node", error)
+	}
+	if len(kademlias[1].getPublished()) != 1 {
+		t.Error("Chunks of a file that was not stored are published", len(kademlias[1].getPublished()))
+	}
+}
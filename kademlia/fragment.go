@@ -0,0 +1,282 @@
+package kademlia
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Largest datagram sent, larger messages are split into fragments
+const maxDatagramSize = 8192
+//Largest message that is reassembled from fragments
+const maxMessageSize = 16 * 1024 * 1024
+//Length of the message header: version, message type, magic value and ID
+const messageHeaderLength = 10 + IDLength
+//Length of the fragment header after the message header: message type, index and count
+const fragmentHeaderLength = 1 + 4 + 4
+//Bytes of the message carried by each fragment
+const fragmentPayloadSize = maxDatagramSize - messageHeaderLength - fragmentHeaderLength
+//Fragments sent before waiting for an acknowledgement
+const fragmentWindowSize = 16
+//Milliseconds to wait for a window to be acknowledged before sending it again
+const fragmentAckTimeout = 1000
+//Times a window is sent before giving up
+const fragmentAttempts = 3
+//Bytes reserved for messages being reassembled from all senders and from one sender,
+//fragments of new messages over either budget are dropped
+const maxReassemblyBytes = 4 * maxMessageSize
+const maxSenderReassemblyBytes = 2 * maxMessageSize
+
+// reassembly definition
+// the fragments of a message received so far
+type reassembly struct {
+	key string
+	sender string
+	//Bytes reserved for the message, the largest size of its fragment count
+	size int
+	fragments [][]byte
+	received int
+	lastUpdate time.Time
+	//Position in the list of reassemblies ordered by last update
+	element *list.Element
+}
+
+// reassembler definition
+// collects fragments of messages by sender, magic value and message type
+// and keeps the acknowledgements awaited for sent windows of fragments
+type reassembler struct {
+	mutex sync.Mutex
+	messages map[string]*reassembly
+	//Reassemblies ordered by last update, the stalest first
+	updates *list.List
+	//Bytes reserved in total and by sender
+	bytes int
+	senderBytes map[string]int
+	acks map[string]chan struct{}
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{messages:make(map[string]*reassembly), updates:list.New(), senderBytes:make(map[string]int), acks:make(map[string]chan struct{})}
+}
+
+//Sends a message, splitting it into fragments if it does not fit in one datagram.
+//Fragments are sent in windows that the receiver acknowledges, so this must not
+//be called from the receiving go routine. Sending stops when the context is cancelled
+func (network *Network) writeMessage(ctx context.Context, data []byte, address *net.UDPAddr) {
+	if len(data) <= maxDatagramSize {
+		network.transport.WriteTo(data, address)
+		return
+	}
+
+	messageType := data[1]
+	magicValue := binary.LittleEndian.Uint64(data[2:10])
+	count := (len(data) + fragmentPayloadSize - 1) / fragmentPayloadSize
+
+	for window := 0; window * fragmentWindowSize < count; window++ {
+		ack := network.addFragmentAck(address, magicValue, messageType, window)
+
+		acknowledged := false
+		for attempt := 0; attempt < fragmentAttempts && !acknowledged; attempt++ {
+			for index := window * fragmentWindowSize; index < count && index < (window + 1) * fragmentWindowSize; index++ {
+				end := (index + 1) * fragmentPayloadSize
+				if end > len(data) {
+					end = len(data)
+				}
+				network.writeFragment(data[index * fragmentPayloadSize:end], address, data[0], magicValue, messageType, index, count)
+			}
+
+			select {
+			case <-ack:
+				acknowledged = true
+			case <-time.After(fragmentAckTimeout * time.Millisecond):
+			case <-network.closed:
+				attempt = fragmentAttempts
+			case <-ctx.Done():
+				attempt = fragmentAttempts
+			}
+		}
+
+		network.removeFragmentAck(address, magicValue, messageType, window)
+		if !acknowledged {
+			//The receiver is gone, the request will time out
+			return
+		}
+	}
+}
+
+func (network *Network) writeFragment(fragment []byte, address *net.UDPAddr, version byte, magicValue uint64, messageType byte, index int, count int) {
+	buffer := new(bytes.Buffer)
+
+	//Version of the message
+	buffer.WriteByte(version)
+
+	//Message type
+	buffer.WriteByte(MessageFragment)
+
+	//Magic value
+	binary.Write(buffer, binary.LittleEndian, magicValue)
+
+	//My ID
+	buffer.Write(network.kademlia.myID[:])
+
+	//Fragmented message type, fragment index and fragment count
+	buffer.WriteByte(messageType)
+	binary.Write(buffer, binary.LittleEndian, uint32(index))
+	binary.Write(buffer, binary.LittleEndian, uint32(count))
+
+	//Fragment of the message
+	buffer.Write(fragment)
+
+	network.transport.WriteTo(buffer.Bytes(), address)
+}
+
+//Acknowledges a window of fragments to their sender
+func (network *Network) writeFragmentAck(address *net.UDPAddr, version byte, magicValue uint64, messageType byte, window int) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteByte(version)
+	buffer.WriteByte(MessageFragmentAck)
+	binary.Write(buffer, binary.LittleEndian, magicValue)
+	buffer.Write(network.kademlia.myID[:])
+	buffer.WriteByte(messageType)
+	binary.Write(buffer, binary.LittleEndian, uint32(window))
+
+	network.transport.WriteTo(buffer.Bytes(), address)
+}
+
+func fragmentAckKey(address *net.UDPAddr, magicValue uint64, messageType byte, window int) string {
+	return memoryAddressKey(address) + "/" + strconv.FormatUint(magicValue, 16) + "/" + strconv.Itoa(int(messageType)) + "/" + strconv.Itoa(window)
+}
+
+//Returns a channel that is closed when the window is acknowledged
+func (network *Network) addFragmentAck(address *net.UDPAddr, magicValue uint64, messageType byte, window int) chan struct{} {
+	ack := make(chan struct{})
+	network.reassembler.mutex.Lock()
+	network.reassembler.acks[fragmentAckKey(address, magicValue, messageType, window)] = ack
+	network.reassembler.mutex.Unlock()
+	return ack
+}
+
+func (network *Network) removeFragmentAck(address *net.UDPAddr, magicValue uint64, messageType byte, window int) {
+	network.reassembler.mutex.Lock()
+	delete(network.reassembler.acks, fragmentAckKey(address, magicValue, messageType, window))
+	network.reassembler.mutex.Unlock()
+}
+
+//Wakes up the sender of an acknowledged window
+func (network *Network) handleFragmentAck(senderAddress *net.UDPAddr, magicValue uint64, data []byte) error {
+	messageType, window, error := decodeFragmentAck(data)
+	if error != nil {
+		return error
+	}
+	key := fragmentAckKey(senderAddress, magicValue, messageType, window)
+
+	network.reassembler.mutex.Lock()
+	if ack, exists := network.reassembler.acks[key]; exists {
+		close(ack)
+		delete(network.reassembler.acks, key)
+	}
+	network.reassembler.mutex.Unlock()
+	return nil
+}
+
+//Adds a fragment and handles the message once all fragments are received
+//Returns an error if the fragment or the reassembled message is malformed
+func (network *Network) handleFragment(senderAddress *net.UDPAddr, version byte, magicValue uint64, data []byte) error {
+	messageType, index, count, fragment, error := decodeFragment(data)
+	if errors.Is(error, ErrValueTooLarge) && !isResponse(messageType) {
+		//Tell the requester instead of letting it resend the fragments until it times out
+		network.SendErrorResponse(senderAddress, version, magicValue, ErrorValueTooLarge, "messages are limited to " + strconv.Itoa(maxMessageSize) + " bytes")
+	}
+	if error != nil {
+		return error
+	}
+
+	key := memoryAddressKey(senderAddress) + "/" + strconv.FormatUint(magicValue, 16) + "/" + strconv.Itoa(int(messageType))
+
+	reassembler := network.reassembler
+	reassembler.mutex.Lock()
+	reassembler.removeStale()
+	message, exists := reassembler.messages[key]
+	if !exists {
+		sender := senderAddress.IP.String()
+		size := count * fragmentPayloadSize
+		if reassembler.bytes + size > maxReassemblyBytes || reassembler.senderBytes[sender] + size > maxSenderReassemblyBytes {
+			reassembler.mutex.Unlock()
+			return errors.New("fragment over the reassembly budget")
+		}
+		message = &reassembly{key:key, sender:sender, size:size, fragments:make([][]byte, count)}
+		message.element = reassembler.updates.PushBack(message)
+		reassembler.messages[key] = message
+		reassembler.bytes += size
+		reassembler.senderBytes[sender] += size
+	}
+	if len(message.fragments) != count {
+		reassembler.mutex.Unlock()
+		return errors.New("fragment count differs from earlier fragments")
+	}
+	if message.fragments[index] == nil {
+		message.fragments[index] = append([]byte(nil), fragment...)
+		message.received++
+		message.lastUpdate = time.Now()
+		reassembler.updates.MoveToBack(message.element)
+	}
+
+	//Acknowledge the window once all of its fragments are received, again if it was resent
+	window := index / fragmentWindowSize
+	windowComplete := true
+	for i := window * fragmentWindowSize; i < count && i < (window + 1) * fragmentWindowSize; i++ {
+		windowComplete = windowComplete && message.fragments[i] != nil
+	}
+
+	complete := message.received == count
+	if complete {
+		reassembler.remove(message)
+	}
+	reassembler.mutex.Unlock()
+
+	if windowComplete {
+		network.writeFragmentAck(senderAddress, version, magicValue, messageType, window)
+	}
+
+	if complete {
+		data := bytes.Join(message.fragments, nil)
+		header, _, error := decodeHeader(data)
+		if error != nil {
+			return error
+		}
+		if header.version != version || header.messageType != messageType || header.magicValue != magicValue {
+			return errors.New("reassembled message differs from its fragments")
+		}
+		return network.handleNetworkData(senderAddress, data)
+	}
+	return nil
+}
+
+//Removes messages that have not received fragments within the response timeout,
+//only the stalest messages are looked at
+func (reassembler *reassembler) removeStale() {
+	for element := reassembler.updates.Front(); element != nil; element = reassembler.updates.Front() {
+		message := element.Value.(*reassembly)
+		if time.Since(message.lastUpdate) <= responseTimeout * time.Millisecond {
+			return
+		}
+		reassembler.remove(message)
+	}
+}
+
+//Removes a message and releases the bytes reserved for it
+func (reassembler *reassembler) remove(message *reassembly) {
+	delete(reassembler.messages, message.key)
+	reassembler.updates.Remove(message.element)
+	reassembler.bytes -= message.size
+	reassembler.senderBytes[message.sender] -= message.size
+	if reassembler.senderBytes[message.sender] == 0 {
+		delete(reassembler.senderBytes, message.sender)
+	}
+}
@@ -0,0 +1,134 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Time a lookup or store may take before the request fails with 504 Gateway Timeout
+const httpLookupTimeout = 30 * time.Second
+//Largest object accepted, it must fit in a STORE message with its TTL
+const httpMaxObjectSize = maxMessageSize - messageHeaderLength - 8
+
+// httpHandler definition
+// serves objects over a REST API:
+//   POST /objects         stores the body and returns its hash
+//   GET  /objects/{hash}  returns the object with the hash
+//   GET  /stats           returns the number of malformed messages received
+type httpHandler struct {
+	node *Node
+	lookupTimeout time.Duration
+}
+
+// NewHTTPHandler returns a http.Handler serving the object REST API of a node
+func NewHTTPHandler(node *Node) http.Handler {
+	return &httpHandler{node:node, lookupTimeout:httpLookupTimeout}
+}
+
+func (handler *httpHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimSuffix(request.URL.Path, "/")
+
+	switch {
+	case path == "/objects":
+		if request.Method != http.MethodPost {
+			writer.Header().Set("Allow", http.MethodPost)
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.postObject(writer, request)
+	case strings.HasPrefix(path, "/objects/"):
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			writer.Header().Set("Allow", http.MethodGet + ", " + http.MethodHead)
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.getObject(writer, request, strings.TrimPrefix(path, "/objects/"))
+	case path == "/stats":
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			writer.Header().Set("Allow", http.MethodGet + ", " + http.MethodHead)
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(writer, "malformed %d\n", handler.node.network.MalformedMessages())
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+//Stores the request body and responds with its hash
+func (handler *httpHandler) postObject(writer http.ResponseWriter, request *http.Request) {
+	data, error := io.ReadAll(http.MaxBytesReader(writer, request.Body, httpMaxObjectSize))
+	if error != nil {
+		http.Error(writer, "object too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	//Stop storing when the client disconnects, the lookup takes too long or the node stops
+	ctx, cancel := context.WithTimeout(request.Context(), handler.lookupTimeout)
+	defer cancel()
+
+	hash, error := handler.node.StoreData(ctx, data)
+	if error != nil {
+		writeLookupError(writer, error)
+		return
+	}
+	hashString := hex.EncodeToString(hash[:])
+
+	writer.Header().Set("Location", "/objects/" + hashString)
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.WriteHeader(http.StatusCreated)
+	io.WriteString(writer, hashString + "\n")
+}
+
+//Responds 504 Gateway Timeout if the lookup timed out or no node answered and 503
+//Service Unavailable if the node is stopped or every node refused the object.
+//Nothing is written to clients that disconnected
+func writeLookupError(writer http.ResponseWriter, error error) {
+	switch {
+	case errors.Is(error, context.Canceled):
+	case errors.Is(error, context.DeadlineExceeded):
+		http.Error(writer, "lookup timed out", http.StatusGatewayTimeout)
+	case errors.Is(error, ErrNoResponse):
+		http.Error(writer, "no node answered", http.StatusGatewayTimeout)
+	default:
+		http.Error(writer, error.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+//Looks up the object with the hash
+func (handler *httpHandler) getObject(writer http.ResponseWriter, request *http.Request, hashString string) {
+	var hash [20]byte
+	decoded, error := hex.DecodeString(hashString)
+	if error != nil || len(decoded) != len(hash) {
+		http.Error(writer, "hash must be 40 hexadecimal characters", http.StatusBadRequest)
+		return
+	}
+	copy(hash[:], decoded)
+
+	//Stop looking up when the client disconnects, the lookup takes too long or the node stops
+	ctx, cancel := context.WithTimeout(request.Context(), handler.lookupTimeout)
+	defer cancel()
+
+	data, error := handler.node.LookupData(ctx, hash)
+	if error != nil {
+		writeLookupError(writer, error)
+		return
+	}
+	if data == nil {
+		http.Error(writer, "object not found", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	writer.WriteHeader(http.StatusOK)
+	if request.Method == http.MethodGet {
+		writer.Write(data)
+	}
+}
@@ -0,0 +1,117 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	nodes := newMemoryNodes(t, 5)
+	handler := NewHTTPHandler(nodes[1])
+
+	//Store
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("Hello")))
+	if recorder.Code != http.StatusCreated {
+		t.Error("Incorrect status when storing", recorder.Code)
+	}
+	hash := strings.TrimSpace(recorder.Body.String())
+	if hash != "f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0" || recorder.Header().Get("Location") != "/objects/" + hash {
+		t.Error("Incorrect hash or location (" + hash + ")")
+	}
+
+	//Fetch
+	getHandler := NewHTTPHandler(nodes[2])
+	recorder = httptest.NewRecorder()
+	getHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/" + hash, nil))
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), []byte("Hello")) {
+		t.Error("Could not fetch object", recorder.Code)
+	}
+
+	//Missing object
+	recorder = httptest.NewRecorder()
+	getHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/0000000000000000000000000000000000000000", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Error("Incorrect status for missing object", recorder.Code)
+	}
+
+	//Malformed hash
+	recorder = httptest.NewRecorder()
+	getHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/hej", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Error("Incorrect status for malformed hash", recorder.Code)
+	}
+
+	//Stats
+	recorder = httptest.NewRecorder()
+	getHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "malformed 0\n" {
+		t.Error("Incorrect stats", recorder.Code, recorder.Body.String())
+	}
+
+	//Wrong method
+	recorder = httptest.NewRecorder()
+	getHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/objects/" + hash, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Error("Incorrect status for wrong method", recorder.Code)
+	}
+
+	//Lookup timeout, the only contact never answers
+	transport, _ := NewMemorySwitchboard().NewTransport(&net.UDPAddr{IP: net.ParseIP("10.0.0.0"), Port: StandardPort})
+	silent := NewNodeWithTransport(NewRandomKademliaID(), transport)
+	contact := NewContact(NewRandomKademliaID(), net.ParseIP("10.1.0.0"))
+	silent.kademlia.AddContact(&contact)
+	silent.Start(context.Background())
+	defer silent.Stop()
+	timeoutHandler := &httpHandler{node:silent, lookupTimeout:100 * time.Millisecond}
+	recorder = httptest.NewRecorder()
+	timeoutHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/" + hash, nil))
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Error("Incorrect status for lookup timeout", recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	timeoutHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("Hello")))
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Error("Incorrect status for store timeout", recorder.Code)
+	}
+
+	//No contact can be reached
+	unreachableTransport, _ := NewMemorySwitchboard().NewTransport(&net.UDPAddr{IP: net.ParseIP("10.0.0.0"), Port: StandardPort})
+	unreachableNode := NewNodeWithTransport(NewRandomKademliaID(), unreachableTransport)
+	unreachableNode.kademlia.AddContact(&Contact{ID: NewRandomKademliaID()})
+	unreachableNode.Start(context.Background())
+	defer unreachableNode.Stop()
+	recorder = httptest.NewRecorder()
+	NewHTTPHandler(unreachableNode).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/" + hash, nil))
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Error("Incorrect status when no contact answered", recorder.Code)
+	}
+
+	//Every node refuses the object
+	for _, node := range nodes {
+		node.kademlia.SetQuota(Quota{MaxBytes:1})
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("Refused")))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Error("Incorrect status for refused object", recorder.Code)
+	}
+	if _, published := nodes[1].kademlia.getPublished()[sha1.Sum([]byte("Refused"))]; published {
+		t.Error("Refused object is published")
+	}
+
+	//Stopped node
+	silent.Stop()
+	recorder = httptest.NewRecorder()
+	timeoutHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/objects/" + hash, nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Error("Incorrect status for stopped node", recorder.Code)
+	}
+}
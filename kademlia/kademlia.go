@@ -0,0 +1,327 @@
+// Package kademlia implements a Kademlia distributed hash table node. A Node
+// combines the routing table and storage of a Kademlia with the Network that
+// answers and sends its RPCs over UDP.
+package kademlia
+import (
+  "crypto/sha1"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+//Time a stored value is kept before it expires
+const valueExpiration = 24 * time.Hour
+//Time between sweeps for expired values
+const expirationSweepInterval = time.Minute
+//Time between refreshes of values published by this node, must be less than valueExpiration
+const republishInterval = valueExpiration - time.Hour
+//Time between replications of all stored values to the closest nodes
+const replicationInterval = time.Hour
+//Time without lookups after which a bucket is refreshed
+const bucketRefreshInterval = time.Hour
+
+type Kademlia struct {
+	storage Storage
+	//Held while checking and replacing stored values, reads do not need it
+	storageMutex sync.Mutex
+	quota Quota
+	usage *storageUsage
+	//Hashes of values published by this node, refreshed from their local copies
+	published map[[20]byte]bool
+	publishedMutex sync.RWMutex
+  routing_table *RoutingTable
+	routingTableMutex sync.RWMutex
+	myID *KademliaID
+
+	//Pings a contact, used to check least recently seen contacts of full buckets
+	ping func(contact *Contact) error
+	//Least recently seen contacts currently being pinged
+	pendingPings map[KademliaID]bool
+	//Closed by Close to stop removing expired values
+	closed chan struct{}
+}
+
+//NewKademlia returns a Kademlia that keeps values in memory
+func NewKademlia(id *KademliaID) *Kademlia {
+	return NewKademliaWithStorage(id, NewMemoryStorage())
+}
+
+//NewKademliaWithStorage returns a Kademlia that keeps values in the storage
+func NewKademliaWithStorage(id *KademliaID, storage Storage) *Kademlia {
+  kademlia := &Kademlia{storage:storage, usage:newStorageUsage(), published:make(map[[20]byte]bool), routing_table:NewRoutingTable(id), myID:id, pendingPings:make(map[KademliaID]bool), closed:make(chan struct{})}
+
+	//Count values kept by the storage from before
+	sizes, error := storage.Sizes()
+	if error != nil {
+		log.Println(error)
+	}
+	for hash, size := range sizes {
+		kademlia.usage.add(hash, size)
+	}
+
+	//Remove expired values in the background
+	go func() {
+		for {
+			select {
+			case <-time.After(expirationSweepInterval):
+				kademlia.removeExpiredValues()
+			case <-kademlia.closed:
+				return
+			}
+		}
+	}()
+
+	return kademlia
+}
+
+//SetQuota limits the values stored at this node. Values above a lowered quota
+//are removed by the policy when new values are stored
+func (kademlia *Kademlia) SetQuota(quota Quota) {
+	kademlia.storageMutex.Lock()
+	kademlia.quota = quota
+	kademlia.storageMutex.Unlock()
+}
+
+//Close stops removing expired values and closes the storage
+func (kademlia *Kademlia) Close() error {
+	close(kademlia.closed)
+	kademlia.storageMutex.Lock()
+	defer kademlia.storageMutex.Unlock()
+	return kademlia.storage.Close()
+}
+
+func (kademlia *Kademlia) AddContact(contact *Contact) {
+	kademlia.routingTableMutex.Lock()
+	leastRecentlySeen := kademlia.routing_table.AddContact(*contact)
+
+	//Bucket is full, ping the least recently seen contact and replace it if it does not answer
+	if leastRecentlySeen != nil && kademlia.ping != nil && !kademlia.pendingPings[*leastRecentlySeen.ID] {
+		kademlia.pendingPings[*leastRecentlySeen.ID] = true
+		go kademlia.evictIfUnresponsive(*leastRecentlySeen)
+	}
+	kademlia.routingTableMutex.Unlock()
+}
+
+func (kademlia *Kademlia) AddContacts(contacts []Contact) {
+	for i := range contacts {
+		kademlia.AddContact(&contacts[i])
+	}
+}
+
+//Pings the least recently seen contact of a full bucket without holding the
+//routing table lock and replaces it with a newer contact if the ping fails
+func (kademlia *Kademlia) evictIfUnresponsive(leastRecentlySeen Contact) {
+	//Only a missing answer means the contact is gone, not a closed network at shutdown
+	alive := !errors.Is(kademlia.ping(&leastRecentlySeen), ErrNoResponse)
+
+	kademlia.routingTableMutex.Lock()
+	delete(kademlia.pendingPings, *leastRecentlySeen.ID)
+	if !alive {
+		//The new contact is in the replacement cache and takes its place
+		kademlia.routing_table.RemoveContact(leastRecentlySeen)
+	}
+	kademlia.routingTableMutex.Unlock()
+}
+
+//ID returns the ID of this node
+func (kademlia *Kademlia) ID() *KademliaID {
+	return kademlia.myID
+}
+
+//GetContacts returns all contacts in the routing table, closest first
+func (kademlia *Kademlia) GetContacts() []Contact {
+	kademlia.routingTableMutex.RLock()
+	contacts := kademlia.routing_table.FindClosestContacts(kademlia.myID, IDLength * 8 * bucketSize)
+	kademlia.routingTableMutex.RUnlock()
+	return contacts
+}
+
+//ContactSeen adds a contact that a message was received from and resets its failures
+func (kademlia *Kademlia) ContactSeen(contact *Contact) {
+	kademlia.AddContact(contact)
+	kademlia.routingTableMutex.Lock()
+	kademlia.routing_table.ClearContactFailures(contact.ID)
+	kademlia.routingTableMutex.Unlock()
+}
+
+//MarkContactFailed records a failed RPC to a contact, which is removed
+//from the routing table after repeated failures
+func (kademlia *Kademlia) MarkContactFailed(contact *Contact) {
+	if contact.ID == nil {
+		return
+	}
+	kademlia.routingTableMutex.Lock()
+	kademlia.routing_table.MarkContactFailed(*contact)
+	kademlia.routingTableMutex.Unlock()
+}
+
+func (kademlia *Kademlia) LookupContact(target *KademliaID) []Contact {
+	kademlia.routingTableMutex.RLock()
+	contacts := kademlia.routing_table.FindClosestContacts(target, 20)
+	kademlia.routingTableMutex.RUnlock()
+	return contacts
+}
+
+//MarkContactMisbehaving records that a contact returned incorrect data
+//and removes it from the routing table
+func (kademlia *Kademlia) MarkContactMisbehaving(contact *Contact) {
+	kademlia.routingTableMutex.Lock()
+	kademlia.routing_table.MarkContactMisbehaving(*contact)
+	kademlia.routingTableMutex.Unlock()
+}
+
+//Records a lookup of the target so that its bucket is not refreshed
+func (kademlia *Kademlia) markBucketUsed(target *KademliaID) {
+	kademlia.routingTableMutex.Lock()
+	kademlia.routing_table.MarkBucketUsed(target)
+	kademlia.routingTableMutex.Unlock()
+}
+
+//Returns a random ID in the range of every bucket that needs to be refreshed
+func (kademlia *Kademlia) getBucketRefreshTargets() []*KademliaID {
+	var targets []*KademliaID
+	kademlia.routingTableMutex.RLock()
+	for _, bucketIndex := range kademlia.routing_table.GetIdleBuckets(bucketRefreshInterval) {
+		targets = append(targets, kademlia.routing_table.RandomIDInBucket(bucketIndex))
+	}
+	kademlia.routingTableMutex.RUnlock()
+	return targets
+}
+
+//LookupData returns a copy of the stored data with the hash, nil if it is not stored or expired
+func (kademlia *Kademlia) LookupData(hash [20]byte) []byte {
+	data, expiration, error := kademlia.storage.Get(hash)
+	if error != nil {
+		log.Println(error)
+		return nil
+	}
+  if data != nil && time.Now().Before(expiration) {
+		kademlia.usage.touch(hash)
+    return data
+  }
+  return nil
+}
+
+func (kademlia *Kademlia) Store(data []byte) [20]byte {
+	hash, _ := kademlia.StoreWithTTL(data, valueExpiration)
+	return hash
+}
+
+//StoreWithTTL stores data that expires after ttl. An already stored value
+//keeps its expiration time if it expires later. Returns ErrValueTooLarge if the
+//data is larger than the quota and ErrStorageFull if the quota leaves no room for it
+func (kademlia *Kademlia) StoreWithTTL(data []byte, ttl time.Duration) ([20]byte, error) {
+  hashed_data := sha1.Sum(data)
+	expiration := time.Now().Add(ttl)
+	kademlia.storageMutex.Lock()
+	defer kademlia.storageMutex.Unlock()
+	storedData, storedExpiration, error := kademlia.storage.Get(hashed_data)
+	if error == nil && storedData == nil {
+		error = kademlia.makeRoom(hashed_data, len(data))
+		if error == ErrStorageFull || error == ErrValueTooLarge {
+			return hashed_data, error
+		}
+	}
+	written := error == nil && (storedData == nil || storedExpiration.Before(expiration))
+	if written {
+		error = kademlia.storage.Put(hashed_data, data, expiration)
+	}
+	if error != nil {
+		log.Println(error)
+		return hashed_data, error
+	}
+	//A STORE that changes nothing does not count as a use of the value
+	if written {
+		kademlia.usage.add(hashed_data, len(data))
+	}
+  return hashed_data, nil
+}
+
+//Removes values by the quota policy until a new value of the size fits,
+//must be called with the storage lock held
+func (kademlia *Kademlia) makeRoom(hash [20]byte, size int) error {
+	if kademlia.quota.MaxBytes > 0 && int64(size) > kademlia.quota.MaxBytes {
+		return ErrValueTooLarge
+	}
+	for !kademlia.usage.fits(kademlia.quota, size) {
+		victim, found := kademlia.usage.victim(kademlia.quota.Policy, kademlia.myID, hash)
+		if !found {
+			return ErrStorageFull
+		}
+		if error := kademlia.storage.Delete(victim); error != nil {
+			return error
+		}
+		kademlia.usage.remove(victim)
+	}
+	return nil
+}
+
+//Calls visit with a copy of every stored value that has not expired, reading
+//one value at a time
+func (kademlia *Kademlia) forEachStoredValue(visit func(hash [20]byte, value storedValue)) {
+	expirations, error := kademlia.storage.Expirations()
+	if error != nil {
+		log.Println(error)
+		return
+	}
+
+	for hash, expiration := range expirations {
+		if time.Now().Before(expiration) {
+			if data, expiration, error := kademlia.storage.Get(hash); error == nil && data != nil {
+				visit(hash, storedValue{data:data, expiration:expiration})
+			}
+		}
+	}
+}
+
+//Removes all values whose expiration time has passed
+func (kademlia *Kademlia) removeExpiredValues() {
+	now := time.Now()
+	kademlia.storageMutex.Lock()
+	defer kademlia.storageMutex.Unlock()
+	expirations, error := kademlia.storage.Expirations()
+	if error != nil {
+		log.Println(error)
+		return
+	}
+	for hash, expiration := range expirations {
+		if !now.Before(expiration) {
+			if error := kademlia.storage.Delete(hash); error != nil {
+				log.Println(error)
+			} else {
+				kademlia.usage.remove(hash)
+			}
+		}
+	}
+}
+
+//Remembers the hash of data published by this node so that it is refreshed before it expires
+func (kademlia *Kademlia) addPublished(hash [20]byte) {
+	kademlia.publishedMutex.Lock()
+	kademlia.published[hash] = true
+	kademlia.publishedMutex.Unlock()
+}
+
+//Forget stops refreshing data published by this node, letting it expire across the network.
+//Returns false if the hash was not published by this node
+func (kademlia *Kademlia) Forget(hash [20]byte) bool {
+	kademlia.publishedMutex.Lock()
+	defer kademlia.publishedMutex.Unlock()
+	if _, ok := kademlia.published[hash]; !ok {
+		return false
+	}
+	delete(kademlia.published, hash)
+	return true
+}
+
+//Returns a copy of the hashes of the data published by this node
+func (kademlia *Kademlia) getPublished() map[[20]byte]bool {
+	kademlia.publishedMutex.RLock()
+	published := make(map[[20]byte]bool, len(kademlia.published))
+	for hash := range kademlia.published {
+		published[hash] = true
+	}
+	kademlia.publishedMutex.RUnlock()
+	return published
+}
@@ -0,0 +1,158 @@
+package kademlia
+
+import (
+	"fmt"
+	"net"
+	"bytes"
+	"encoding/hex"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKademlia(t *testing.T) {
+
+	AddContactTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	contact := NewContact(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc35"), net.ParseIP("192.168.0.2"))
+
+	AddContactTest.AddContact(&contact)
+	if AddContactTest.routing_table.FindClosestContacts(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc35"),1)[0].String() != contact.String() {
+		t.Error("Contact was not added")
+	}
+
+	AddContactsTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	contact1 := NewContact(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc35"), net.ParseIP("192.168.0.2"))
+	contact2 := NewContact(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc36"), net.ParseIP("192.168.0.3"))
+	contact3 := NewContact(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc37"), net.ParseIP("192.168.0.4"))
+
+	AddContactsTest.AddContacts([]Contact{contact1, contact2, contact3})
+	if((AddContactsTest.routing_table.FindClosestContacts(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc35"), 1)[0].String() != contact1.String()) &&
+		 (AddContactsTest.routing_table.FindClosestContacts(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc36"), 1)[0].String() != contact2.String()) &&
+		 (AddContactsTest.routing_table.FindClosestContacts(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc37"), 1)[0].String() != contact3.String())) {
+		t.Error("Contacts were not added")
+	}
+
+	LookupContactTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	LookupContactTest.AddContact(&contact)
+	if(LookupContactTest.LookupContact(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc35"))[0].String() != contact.String()) {
+		t.Error("Contact could not be found")
+	}
+
+	StoreTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	StoreTest.Store([]byte("hej"))
+	var decodedData [20]byte;
+	data,_ := hex.DecodeString("c412b37f8c0484e6db8bce177ae88c5443b26e92")
+	copy(decodedData[0:20], data[:]);
+	if storedData, _, _ := StoreTest.storage.Get(decodedData); !bytes.Equal(storedData, []byte("hej")) {
+		t.Error("Could not find data")
+	}
+
+	LookupDataTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	LookupDataTest.Store([]byte("hej"))
+	if !bytes.Equal(LookupDataTest.LookupData(decodedData), []byte("hej")) {
+		t.Error("Could not find data")
+	}
+
+	ExpirationTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	ExpirationTest.Store([]byte("hej"))
+	ExpirationTest.storage.Put(decodedData, []byte("hej"), time.Now().Add(-time.Second))
+	if ExpirationTest.LookupData(decodedData) != nil {
+		t.Error("Found expired data")
+	}
+	ExpirationTest.removeExpiredValues()
+	if storedData, _, _ := ExpirationTest.storage.Get(decodedData); storedData != nil {
+		t.Error("Expired data was not removed")
+	}
+
+	ForgetTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	ForgetTest.addPublished(decodedData)
+	if len(ForgetTest.getPublished()) != 1 {
+		t.Error("Published data was not remembered")
+	}
+	if !ForgetTest.Forget(decodedData) {
+		t.Error("Could not forget published data")
+	}
+	if ForgetTest.Forget(decodedData) || len(ForgetTest.getPublished()) != 0 {
+		t.Error("Forgotten data is still published")
+	}
+
+	TTLTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	TTLTest.StoreWithTTL([]byte("hej"), time.Hour)
+	TTLTest.StoreWithTTL([]byte("hej"), time.Minute)
+	if _, expiration, _ := TTLTest.storage.Get(decodedData); time.Until(expiration) < 59 * time.Minute {
+		t.Error("Shorter TTL replaced a later expiration")
+	}
+	visited := 0
+	TTLTest.forEachStoredValue(func(hash [20]byte, value storedValue) {
+		visited++
+	})
+	if visited != 1 {
+		t.Error("Stored value was not returned")
+	}
+
+	EvictionTest := NewKademlia(NewKademliaID("d406303f608bf7270f34dbd7c55d49cf767bbc34"))
+
+	var pingError error
+	pingMutex := sync.Mutex{}
+	EvictionTest.ping = func(contact *Contact) error {
+		pingMutex.Lock()
+		defer pingMutex.Unlock()
+		return pingError
+	}
+	for i := 0; i < bucketSize; i++ {
+		contact := NewContact(NewKademliaID(fmt.Sprintf("%040x", i + 1)), net.ParseIP("192.168.0.2"))
+		EvictionTest.AddContact(&contact)
+	}
+
+	//Least recently seen contact answers and is kept
+	newContact := NewContact(NewKademliaID(fmt.Sprintf("%040x", bucketSize + 1)), net.ParseIP("192.168.0.3"))
+	EvictionTest.AddContact(&newContact)
+	waitForPendingPings(EvictionTest)
+	if EvictionTest.LookupContact(newContact.ID)[0].ID.Equals(newContact.ID) {
+		t.Error("Responsive contact was evicted")
+	}
+
+	//Pings failing because the network is closed do not evict the contact
+	pingMutex.Lock()
+	pingError = net.ErrClosed
+	pingMutex.Unlock()
+	EvictionTest.AddContact(&newContact)
+	waitForPendingPings(EvictionTest)
+	if EvictionTest.LookupContact(newContact.ID)[0].ID.Equals(newContact.ID) {
+		t.Error("Contact was evicted by a ping failing at shutdown")
+	}
+
+	//Least recently seen contact does not answer and is replaced
+	pingMutex.Lock()
+	pingError = ErrNoResponse
+	pingMutex.Unlock()
+	EvictionTest.AddContact(&newContact)
+	waitForPendingPings(EvictionTest)
+	if !EvictionTest.LookupContact(newContact.ID)[0].ID.Equals(newContact.ID) {
+		t.Error("Unresponsive contact was not evicted")
+	}
+	oldest := NewKademliaID(fmt.Sprintf("%040x", 1))
+	if EvictionTest.LookupContact(oldest)[0].ID.Equals(oldest) {
+		t.Error("Least recently seen contact is still in the bucket")
+	}
+}
+
+func waitForPendingPings(kademlia *Kademlia) {
+	for {
+		kademlia.routingTableMutex.RLock()
+		pending := len(kademlia.pendingPings)
+		kademlia.routingTableMutex.RUnlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
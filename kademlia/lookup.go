@@ -0,0 +1,427 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Number of nodes data is stored at
+const ReplicationFactor = 5
+
+func sortContactsByTargetDistance(contacts []Contact, target *KademliaID) {
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return contacts[i].ID.CalcDistance(target).Less(contacts[j].ID.CalcDistance(target))
+	})
+}
+
+func LookupContact(kademlia *Kademlia, network *Network, target *KademliaID, maxCount int) []Contact {
+	return LookupContactContext(context.Background(), kademlia, network, target, maxCount)
+}
+
+//LookupContactContext is LookupContact that stops early and returns the contacts
+//found so far when the context is cancelled
+func LookupContactContext(ctx context.Context, kademlia *Kademlia, network *Network, target *KademliaID, maxCount int) []Contact {
+	simultaneousLookups := 3
+	maxLookupsSinceBestFound := 6
+
+	uniqueContacts := make(map[KademliaID]bool)
+	kademlia.markBucketUsed(target)
+
+	//Local lookup
+	contacts := kademlia.LookupContact(target);
+	if len(contacts) == 0 {
+		return nil
+	}
+	sortContactsByTargetDistance(contacts, target)
+
+	allContacts := make([]Contact, len(contacts))
+	copy(allContacts, contacts)
+
+	for _, contact := range contacts {
+		uniqueContacts[*contact.ID] = true
+	}
+
+	mutex := sync.Mutex{}
+	condition := sync.NewCond(&mutex)
+	bestContact := contacts[0]
+	lookupsSinceBestFound := 0
+	currentLookups := 0
+
+	finished := func() bool {
+		return ctx.Err() != nil || lookupsSinceBestFound > maxLookupsSinceBestFound || (len(contacts) == 0 && currentLookups == 0)
+	}
+
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < simultaneousLookups; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for !finished() {
+				if len(contacts) == 0 {
+					//Wait for lookups in progress to return more contacts
+					condition.Wait()
+					continue
+				}
+
+				contact := contacts[0]
+
+				//Remove first element
+				contacts = contacts[1:]
+
+				currentLookups++
+				mutex.Unlock()
+
+				newContacts, error := network.SendFindContactMessageContext(ctx, &contact, target)
+				success := error == nil
+				if unreachable(error) {
+					kademlia.MarkContactFailed(&contact)
+				}
+				mutex.Lock()
+				currentLookups--
+				if success {
+					for _, contact := range newContacts {
+						_, exists := uniqueContacts[*contact.ID]
+						if !exists {
+							contacts = append(contacts, contact)
+							allContacts = append(allContacts, contact)
+							uniqueContacts[*contact.ID] = true
+						}
+					}
+					sortContactsByTargetDistance(contacts, target)
+
+					if len(contacts) > 0 {
+						if contacts[0].ID.CalcDistance(target).Less(bestContact.ID.CalcDistance(target)) {
+							bestContact = contacts[0]
+							if lookupsSinceBestFound <= maxLookupsSinceBestFound {
+								lookupsSinceBestFound = 0
+							}
+						}
+					}
+					lookupsSinceBestFound++
+				}
+				condition.Broadcast()
+			}
+			condition.Broadcast()
+		}()
+	}
+
+	//Wait until go routines are finished
+	waitForLookups(ctx, &waitGroup, condition)
+
+	sortContactsByTargetDistance(allContacts, target)
+
+	if len(allContacts) < maxCount {
+		maxCount = len(allContacts)
+	}
+	return allContacts[:maxCount]
+}
+
+//Waits for the lookup go routines, waking up waiting ones if the context is cancelled
+func waitForLookups(ctx context.Context, waitGroup *sync.WaitGroup, condition *sync.Cond) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			condition.L.Lock()
+			condition.Broadcast()
+			condition.L.Unlock()
+		case <-done:
+		}
+	}()
+
+	waitGroup.Wait()
+	close(done)
+}
+
+func LookupData(kademlia *Kademlia, network *Network, hash [20]byte) []byte {
+	return LookupDataContext(context.Background(), kademlia, network, hash)
+}
+
+//LookupDataContext is LookupData that stops early and returns nil when the
+//context is cancelled
+func LookupDataContext(ctx context.Context, kademlia *Kademlia, network *Network, hash [20]byte) []byte {
+	data, _ := lookupData(ctx, kademlia, network, hash)
+	return data
+}
+
+//Looks up the data with the hash. Returns the context error when the context is
+//cancelled and ErrNoResponse if none of the contacts asked for the data answered
+func lookupData(ctx context.Context, kademlia *Kademlia, network *Network, hash [20]byte) ([]byte, error) {
+	//Local data lookup
+	data := kademlia.LookupData(hash)
+	if data != nil {
+		return data, nil
+	}
+
+	target := NewKademliaIDFromBytes(hash[:])
+
+	simultaneousLookups := 3
+	maxLookupsSinceBestFound := 6
+
+	uniqueContacts := make(map[KademliaID]bool)
+	kademlia.markBucketUsed(target)
+
+	//Local node lookup
+	contacts := kademlia.LookupContact(target);
+	if len(contacts) == 0 {
+		return nil, nil
+	}
+	sortContactsByTargetDistance(contacts, target)
+
+	for _, contact := range contacts {
+		uniqueContacts[*contact.ID] = true
+	}
+
+	mutex := sync.Mutex{}
+	condition := sync.NewCond(&mutex)
+	bestContact := contacts[0]
+	lookupsSinceBestFound := 0
+	currentLookups := 0
+	answered := false
+
+	finished := func() bool {
+		return data != nil || ctx.Err() != nil || lookupsSinceBestFound > maxLookupsSinceBestFound || (len(contacts) == 0 && currentLookups == 0)
+	}
+
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < simultaneousLookups; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for !finished() {
+				if len(contacts) == 0 {
+					//Wait for lookups in progress to return more contacts
+					condition.Wait()
+					continue
+				}
+
+				contact := contacts[0]
+
+				//Remove first element
+				contacts = contacts[1:]
+
+				currentLookups++
+				mutex.Unlock()
+
+				newContacts, newData, error := network.SendFindDataMessageContext(ctx, &contact, hash)
+				success := error == nil
+				if unreachable(error) {
+					kademlia.MarkContactFailed(&contact)
+				}
+				if success && len(newData) > 0 && sha1.Sum(newData) != hash {
+					//Discard data that does not match the hash and continue with other contacts
+					kademlia.MarkContactMisbehaving(&contact)
+					newData = nil
+				}
+				mutex.Lock()
+				currentLookups--
+				answered = answered || !unreachable(error)
+				if success {
+					if len(newData) > 0 {
+						if data == nil {
+							data = newData
+						}
+						break
+					}
+
+					for _, contact := range newContacts {
+						_, exists := uniqueContacts[*contact.ID]
+						if !exists {
+							contacts = append(contacts, contact)
+							uniqueContacts[*contact.ID] = true
+						}
+					}
+					sortContactsByTargetDistance(contacts, target)
+
+					if len(contacts) > 0 {
+						if contacts[0].ID.CalcDistance(target).Less(bestContact.ID.CalcDistance(target)) {
+							bestContact = contacts[0]
+							if lookupsSinceBestFound <= maxLookupsSinceBestFound {
+								lookupsSinceBestFound = 0
+							}
+						}
+					}
+					lookupsSinceBestFound++
+				}
+				condition.Broadcast()
+			}
+			condition.Broadcast()
+		}()
+	}
+
+	//Wait until go routines are finished
+	waitForLookups(ctx, &waitGroup, condition)
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if data == nil && !answered {
+		return nil, ErrNoResponse
+	}
+	return data, nil
+}
+
+//Returns true if a request failed because the contact could not be reached, error
+//responses and cancelled requests do not make a contact fail
+func unreachable(error error) bool {
+	return errors.Is(error, ErrNoResponse) || errors.Is(error, ErrNoAddress)
+}
+
+func StoreData(kademlia *Kademlia, network *Network, data []byte, replicationFactor int) [20]byte {
+	hash, _ := StoreDataContext(context.Background(), kademlia, network, data, replicationFactor)
+	return hash
+}
+
+//StoreDataContext is StoreData that returns an error if no node stored the data.
+//The lookup and the STOREs are abandoned when the context is cancelled
+func StoreDataContext(ctx context.Context, kademlia *Kademlia, network *Network, data []byte, replicationFactor int) ([20]byte, error) {
+	hash, error := storeData(ctx, kademlia, network, data, replicationFactor, valueExpiration)
+
+	//Remember data stored by any node so it is refreshed from a local copy until forgotten
+	if error == nil {
+		kademlia.StoreWithTTL(data, valueExpiration)
+		kademlia.addPublished(hash)
+	}
+
+	return hash, error
+}
+
+//Sends data that expires after ttl to the replicationFactor closest nodes and waits
+//for their answers. Returns an error if no node stored the data, the refusal of a
+//node if any refused it and otherwise ErrNoResponse
+func storeData(ctx context.Context, kademlia *Kademlia, network *Network, data []byte, replicationFactor int, ttl time.Duration) ([20]byte, error) {
+	hash := sha1.Sum(data)
+
+	target := NewKademliaIDFromBytes(hash[:])
+	contacts := LookupContactContext(ctx, kademlia, network, target, replicationFactor)
+
+	if len(contacts) == 0 {
+		if ctx.Err() != nil {
+			return hash, ctx.Err()
+		}
+		_, error := kademlia.StoreWithTTL(data, ttl)
+		return hash, error
+	}
+	if len(contacts) < replicationFactor {
+		replicationFactor = len(contacts)
+	}
+
+	stored := 0
+	var refusal error
+	mutex := sync.Mutex{}
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < replicationFactor; i++ {
+		waitGroup.Add(1)
+		go func(contact *Contact) {
+			defer waitGroup.Done()
+			error := network.SendStoreMessageContext(ctx, contact, data, ttl)
+			//A full node answered, so only missing answers count as failures
+			if unreachable(error) {
+				kademlia.MarkContactFailed(contact)
+			}
+
+			var remoteError *RemoteError
+			mutex.Lock()
+			if error == nil {
+				stored++
+			} else if errors.As(error, &remoteError) {
+				refusal = error
+			}
+			mutex.Unlock()
+		}(&contacts[i])
+	}
+	waitGroup.Wait()
+
+	switch {
+	case stored > 0:
+		return hash, nil
+	case ctx.Err() != nil:
+		return hash, ctx.Err()
+	case refusal != nil:
+		return hash, refusal
+	}
+	return hash, ErrNoResponse
+}
+
+//Waits for the interval, returns false if the context is cancelled first
+func sleepContext(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+//Refreshes data published by this node before it expires
+func republishLoop(ctx context.Context, kademlia *Kademlia, network *Network) {
+	for sleepContext(ctx, republishInterval) {
+		for hash := range kademlia.getPublished() {
+			republish(ctx, kademlia, network, hash)
+		}
+	}
+}
+
+//Stores published data again, read from the local copy or looked up if the
+//local copy was evicted, and refreshes the local copy
+func republish(ctx context.Context, kademlia *Kademlia, network *Network, hash [20]byte) {
+	data := kademlia.LookupData(hash)
+	if data == nil {
+		data = LookupDataContext(ctx, kademlia, network, hash)
+	}
+	if data == nil {
+		log.Println("Published value " + hex.EncodeToString(hash[:]) + " was not found")
+		return
+	}
+	if _, error := storeData(ctx, kademlia, network, data, ReplicationFactor, valueExpiration); error == nil {
+		kademlia.StoreWithTTL(data, valueExpiration)
+	}
+}
+
+//Replicates stored values to the nodes currently closest to them, keeping their
+//remaining time to live so that forgotten values still expire
+func replicationLoop(ctx context.Context, kademlia *Kademlia, network *Network) {
+	for sleepContext(ctx, replicationInterval) {
+		kademlia.forEachStoredValue(func(hash [20]byte, value storedValue) {
+			ttl := time.Until(value.expiration)
+			if ttl > 0 && ctx.Err() == nil {
+				storeData(ctx, kademlia, network, value.data, ReplicationFactor, ttl)
+			}
+		})
+	}
+}
+
+//Buckets refreshed at the same time
+const bucketRefreshConcurrency = 3
+
+//Refreshes buckets without lookups in their range by looking up a random ID in them
+func bucketRefreshLoop(ctx context.Context, kademlia *Kademlia, network *Network) {
+	for sleepContext(ctx, bucketRefreshInterval / 6) {
+		semaphore := make(chan struct{}, bucketRefreshConcurrency)
+		waitGroup := sync.WaitGroup{}
+		for _, target := range kademlia.getBucketRefreshTargets() {
+			semaphore <- struct{}{}
+			waitGroup.Add(1)
+			go func(target *KademliaID) {
+				defer waitGroup.Done()
+				kademlia.AddContacts(LookupContactContext(ctx, kademlia, network, target, bucketSize))
+				<-semaphore
+			}(target)
+		}
+		waitGroup.Wait()
+	}
+}
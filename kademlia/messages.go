@@ -0,0 +1,173 @@
+package kademlia
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MessagePing = 0
+	MessageStore = 1
+	MessageFindNode = 2
+	MessageFindValue = 3
+	ResponsePing = 4
+	ResponseStore = 5
+	ResponseFindNode = 6
+	ResponseFindValue = 7
+	MessageFragment = 8
+	MessageFragmentAck = 9
+	ResponseError = 10
+)
+
+//Longest text of an error response, longer texts are cut
+const maxErrorTextLength = 256
+
+//Returns true if the message type answers a request
+func isResponse(messageType byte) bool {
+	return messageType == ResponsePing || messageType == ResponseStore || messageType == ResponseFindNode || messageType == ResponseFindValue || messageType == ResponseError
+}
+
+//Returns the type of the response to a request, requests of unknown types
+//can only be answered with an error response
+func responseTypeOf(requestType byte) byte {
+	switch requestType {
+	case MessagePing:
+		return ResponsePing
+	case MessageStore:
+		return ResponseStore
+	case MessageFindNode:
+		return ResponseFindNode
+	case MessageFindValue:
+		return ResponseFindValue
+	}
+	return ResponseError
+}
+
+// messageHeader definition
+// the header starting messages of every version
+type messageHeader struct {
+	version byte
+	messageType byte
+	magicValue uint64
+	id *KademliaID
+}
+
+//Decodes the header of a message and returns the data following it
+func decodeHeader(data []byte) (messageHeader, []byte, error) {
+	if len(data) < messageHeaderLength {
+		return messageHeader{}, nil, errors.New("message shorter than its header")
+	}
+	header := messageHeader{
+		version:data[0],
+		messageType:data[1],
+		magicValue:binary.LittleEndian.Uint64(data[2:10]),
+		id:NewKademliaIDFromBytes(data[10:messageHeaderLength]),
+	}
+	return header, data[messageHeaderLength:], nil
+}
+
+//Decodes the versions sent in a ping or its response, contacts that do not send
+//them only speak the version of the message
+func decodePing(version byte, data []byte) (byte, byte, error) {
+	switch {
+	case len(data) == 0:
+		return version, version, nil
+	case len(data) != 2:
+		return 0, 0, errors.New("PING with incorrect length")
+	case data[0] > data[1]:
+		return 0, 0, errors.New("PING with empty version range")
+	}
+	return data[0], data[1], nil
+}
+
+//Decodes a STORE request into the time to live and the value
+func decodeStore(data []byte) (time.Duration, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, errors.New("STORE without TTL")
+	}
+	milliseconds := binary.LittleEndian.Uint64(data[0:8])
+	if milliseconds > uint64(valueExpiration.Milliseconds()) {
+		milliseconds = uint64(valueExpiration.Milliseconds())
+	}
+	return time.Duration(milliseconds) * time.Millisecond, data[8:], nil
+}
+
+//Decodes a FIND_NODE request into the target ID
+func decodeFindNode(data []byte) (*KademliaID, error) {
+	if len(data) != IDLength {
+		return nil, errors.New("FIND_NODE with incorrect ID length")
+	}
+	return NewKademliaIDFromBytes(data), nil
+}
+
+//Decodes a FIND_VALUE request into the hash of the value
+func decodeFindValue(data []byte) ([20]byte, error) {
+	var hash [20]byte
+	if len(data) != len(hash) {
+		return hash, errors.New("FIND_VALUE with incorrect hash length")
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
+
+//Decodes a FIND_VALUE response into closer contacts or a copy of the value
+func decodeFindValueResponse(data []byte) ([]Contact, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("empty FIND_VALUE response")
+	}
+	switch data[0] {
+	case 0:
+		contacts, error := dataToContacts(data[1:])
+		return contacts, nil, error
+	case 1:
+		//Copy since the receive buffer is reused
+		return nil, append([]byte(nil), data[1:]...), nil
+	}
+	return nil, nil, errors.New("FIND_VALUE response with unknown kind")
+}
+
+//Decodes an error response
+func decodeErrorResponse(data []byte) (*RemoteError, error) {
+	if len(data) == 0 {
+		return nil, errors.New("error response without code")
+	}
+	text := data[1:]
+	if len(text) > maxErrorTextLength {
+		text = text[:maxErrorTextLength]
+	}
+	return &RemoteError{Code:ErrorCode(data[0]), Text:string(text)}, nil
+}
+
+//Decodes a fragment into the fragmented message type, the index and count of the
+//fragment and the part of the message it carries. Returns an error matching
+//ErrValueTooLarge with the message type if the message is larger than maxMessageSize
+func decodeFragment(data []byte) (byte, int, int, []byte, error) {
+	if len(data) < fragmentHeaderLength {
+		return 0, 0, 0, nil, errors.New("fragment shorter than its header")
+	}
+	messageType := data[0]
+	index := binary.LittleEndian.Uint32(data[1:5])
+	count := binary.LittleEndian.Uint32(data[5:9])
+	fragment := data[fragmentHeaderLength:]
+
+	if messageType == MessageFragment || messageType == MessageFragmentAck {
+		return 0, 0, 0, nil, errors.New("fragment of a fragment")
+	}
+	if count > maxMessageSize / fragmentPayloadSize + 1 {
+		return messageType, 0, 0, nil, fmt.Errorf("fragment of a message with %d fragments: %w", count, ErrValueTooLarge)
+	}
+	if count < 2 || index >= count || len(fragment) == 0 || len(fragment) > fragmentPayloadSize {
+		return 0, 0, 0, nil, errors.New("fragment with incorrect index, count or length")
+	}
+	return messageType, int(index), int(count), fragment, nil
+}
+
+//Decodes a fragment acknowledgement into the fragmented message type and the window
+func decodeFragmentAck(data []byte) (byte, int, error) {
+	if len(data) != 5 {
+		return 0, 0, errors.New("fragment acknowledgement with incorrect length")
+	}
+	return data[0], int(binary.LittleEndian.Uint32(data[1:5])), nil
+}
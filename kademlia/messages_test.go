@@ -0,0 +1,256 @@
+package kademlia
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+//Returns a message with a header of the version, message type and magic value
+func testMessage(version byte, messageType byte, magicValue uint64, data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.WriteByte(version)
+	buffer.WriteByte(messageType)
+	binary.Write(buffer, binary.LittleEndian, magicValue)
+	buffer.Write(NewRandomKademliaID()[:])
+	buffer.Write(data)
+	return buffer.Bytes()
+}
+
+func TestMessages(t *testing.T) {
+	kademlias, networks := newMemoryNetwork(t, 2)
+	contact := NewContact(kademlias[0].myID, net.ParseIP("10.0.0.0"))
+
+	//Truncated and corrupt messages and responses of another type than requested are dropped and counted
+	switchboardTransport := networks[1].transport
+	address := contact.UDPAddress()
+	contactCount := len(kademlias[0].GetContacts())
+	pending := &NetworkResponse{magicValue:7, messageType:ResponseFindValue, address:&net.UDPAddr{IP:net.ParseIP("10.0.0.1"), Port:StandardPort}, done:make(chan struct{})}
+	networks[0].responsesMutex.Lock()
+	networks[0].responses[pending.magicValue] = pending
+	networks[0].responsesMutex.Unlock()
+	malformed := [][]byte{
+		{},
+		{0, MessagePing, 1, 2},
+		testMessage(maxNetworkVersion, ResponseFindNode, 1, []byte{1, 2, 3}),
+		testMessage(maxNetworkVersion, ResponseFindValue, 1, []byte{0, 1}),
+		testMessage(maxNetworkVersion, MessageFragment, 1, []byte{MessageStore, 0, 0, 0, 0, 1}),
+		testMessage(maxNetworkVersion, MessageFragmentAck, 1, []byte{MessageStore}),
+		testMessage(maxNetworkVersion, MessageFindNode, 1, []byte{1}),
+		testMessage(maxNetworkVersion, ResponsePing, pending.magicValue, []byte{0, 1}),
+	}
+	for _, message := range malformed {
+		switchboardTransport.WriteTo(message, address)
+	}
+	deadline := time.Now().Add(time.Second)
+	for networks[0].MalformedMessages() < uint64(len(malformed)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if networks[0].MalformedMessages() != uint64(len(malformed)) {
+		t.Error("Incorrect number of malformed messages", networks[0].MalformedMessages())
+	}
+	if len(kademlias[0].GetContacts()) != contactCount {
+		t.Error("Sender of a malformed message was added to the routing table")
+	}
+	select {
+	case <-pending.done:
+		t.Error("Response of another type completed a request")
+	default:
+	}
+	if networks[1].SendPingMessage(&contact) != nil {
+		t.Error("Node stopped answering after malformed messages")
+	}
+
+	//Requests larger than the message limit are refused from their first fragment
+	tooLarge := &NetworkResponse{magicValue:8, messageType:ResponseStore, address:address, done:make(chan struct{})}
+	networks[1].responsesMutex.Lock()
+	networks[1].responses[tooLarge.magicValue] = tooLarge
+	networks[1].responsesMutex.Unlock()
+	fragment := new(bytes.Buffer)
+	fragment.WriteByte(MessageStore)
+	binary.Write(fragment, binary.LittleEndian, uint32(0))
+	binary.Write(fragment, binary.LittleEndian, uint32(maxMessageSize / fragmentPayloadSize + 2))
+	fragment.WriteByte(1)
+	switchboardTransport.WriteTo(testMessage(maxNetworkVersion, MessageFragment, tooLarge.magicValue, fragment.Bytes()), address)
+	select {
+	case <-tooLarge.done:
+		if !errors.Is(tooLarge.error, ErrValueTooLarge) {
+			t.Error("Too large request was refused with another error", tooLarge.error)
+		}
+	case <-time.After(time.Second):
+		t.Error("Too large request was not refused")
+	}
+
+	//Error texts are limited
+	if remoteError, _ := decodeErrorResponse(append([]byte{byte(ErrorMalformedRequest)}, bytes.Repeat([]byte{'a'}, 2 * maxErrorTextLength)...)); len(remoteError.Text) != maxErrorTextLength {
+		t.Error("Error text was not limited", len(remoteError.Text))
+	}
+
+	//Fragments of new messages over the reassembly budget are dropped
+	maxCount := maxMessageSize / fragmentPayloadSize + 1
+	firstFragment := func(sender string, magicValue uint64) error {
+		fragment := new(bytes.Buffer)
+		fragment.WriteByte(MessageStore)
+		binary.Write(fragment, binary.LittleEndian, uint32(0))
+		binary.Write(fragment, binary.LittleEndian, uint32(maxCount))
+		fragment.WriteByte(1)
+		return networks[0].handleFragment(&net.UDPAddr{IP:net.ParseIP(sender), Port:StandardPort}, maxNetworkVersion, magicValue, fragment.Bytes())
+	}
+	if firstFragment("10.1.0.1", 1) != nil || firstFragment("10.1.0.1", 2) == nil {
+		t.Error("Per sender reassembly budget was not enforced")
+	}
+	if firstFragment("10.1.0.2", 1) != nil || firstFragment("10.1.0.3", 1) != nil || firstFragment("10.1.0.4", 1) == nil {
+		t.Error("Total reassembly budget was not enforced")
+	}
+	networks[0].reassembler.mutex.Lock()
+	for _, message := range networks[0].reassembler.messages {
+		message.lastUpdate = time.Now().Add(-time.Hour)
+	}
+	networks[0].reassembler.mutex.Unlock()
+	if firstFragment("10.1.0.4", 1) != nil || networks[0].reassembler.bytes != maxCount * fragmentPayloadSize {
+		t.Error("Stale reassemblies were not removed")
+	}
+
+	//Contacts must be complete
+	encoded := contactsToData([]Contact{NewContact(NewRandomKademliaID(), net.ParseIP("10.0.0.1"))})
+	if _, error := dataToContacts(encoded[:len(encoded) - 1]); error == nil {
+		t.Error("Decoded a truncated contact")
+	}
+	encoded[IDLength] = 5
+	if _, error := dataToContacts(encoded); error == nil {
+		t.Error("Decoded a contact with an unknown address family")
+	}
+
+	//Time to live is limited to the value expiration
+	if ttl, _, error := decodeStore(bytes.Repeat([]byte{0xff}, 8)); error != nil || ttl != valueExpiration {
+		t.Error("Time to live was not limited", ttl)
+	}
+}
+
+func FuzzDecodeHeader(f *testing.F) {
+	f.Add(testMessage(0, MessagePing, 1, nil))
+	f.Add([]byte{0, MessagePing})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, rest, error := decodeHeader(data)
+		if error == nil && (len(rest) != len(data) - messageHeaderLength || header.id == nil) {
+			t.Error("Incorrect header")
+		}
+	})
+}
+
+func FuzzDataToContacts(f *testing.F) {
+	f.Add(contactsToData([]Contact{NewContact(NewRandomKademliaID(), net.ParseIP("10.0.0.1")), NewContact(NewRandomKademliaID(), net.ParseIP("fd00::1"))}))
+	f.Add([]byte{1, 2, 3})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		contacts, error := dataToContacts(data)
+		if error == nil && !bytes.Equal(contactsToData(contacts), data) {
+			t.Error("Decoded contacts do not encode to the same data")
+		}
+	})
+}
+
+func FuzzDecodePing(f *testing.F) {
+	f.Add(byte(0), []byte{})
+	f.Add(byte(1), []byte{0, 1})
+	f.Add(byte(1), []byte{1, 0})
+	f.Fuzz(func(t *testing.T, version byte, data []byte) {
+		min, max, error := decodePing(version, data)
+		if error == nil && min > max {
+			t.Error("Decoded an empty version range")
+		}
+	})
+}
+
+func FuzzDecodeStore(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 'a'})
+	f.Add([]byte{1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ttl, value, error := decodeStore(data)
+		if error == nil && (ttl < 0 || ttl > valueExpiration || len(value) != len(data) - 8) {
+			t.Error("Incorrect STORE", ttl)
+		}
+	})
+}
+
+func FuzzDecodeFindNode(f *testing.F) {
+	f.Add(NewRandomKademliaID()[:])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if target, error := decodeFindNode(data); error == nil && !bytes.Equal(target[:], data) {
+			t.Error("Incorrect target")
+		}
+	})
+}
+
+func FuzzDecodeFindValue(f *testing.F) {
+	f.Add(NewRandomKademliaID()[:])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if hash, error := decodeFindValue(data); error == nil && !bytes.Equal(hash[:], data) {
+			t.Error("Incorrect hash")
+		}
+	})
+}
+
+func FuzzDecodeFindValueResponse(f *testing.F) {
+	f.Add([]byte{1, 'a'})
+	f.Add(append([]byte{0}, contactsToData([]Contact{NewContact(NewRandomKademliaID(), net.ParseIP("10.0.0.1"))})...))
+	f.Add([]byte{2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		contacts, value, error := decodeFindValueResponse(data)
+		if error == nil && len(contacts) > 0 && value != nil {
+			t.Error("Decoded both contacts and a value")
+		}
+	})
+}
+
+func FuzzDecodeErrorResponse(f *testing.F) {
+	f.Add([]byte{byte(ErrorQuotaExceeded), 'f', 'u', 'l', 'l'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if remoteError, error := decodeErrorResponse(data); error == nil && remoteError.Error() == "" {
+			t.Error("Empty remote error")
+		}
+	})
+}
+
+func FuzzDecodeFragment(f *testing.F) {
+	f.Add([]byte{MessageStore, 1, 0, 0, 0, 2, 0, 0, 0, 'a'})
+	f.Add([]byte{MessageFragment, 0, 0, 0, 0, 2, 0, 0, 0, 'a'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, index, count, fragment, error := decodeFragment(data)
+		if error == nil && (index < 0 || index >= count || len(fragment) > fragmentPayloadSize) {
+			t.Error("Incorrect fragment", index, count)
+		}
+	})
+}
+
+func FuzzDecodeFragmentAck(f *testing.F) {
+	f.Add([]byte{MessageStore, 1, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, window, error := decodeFragmentAck(data); error == nil && window < 0 {
+			t.Error("Negative window")
+		}
+	})
+}
+
+func FuzzHandleNetworkData(f *testing.F) {
+	f.Add(testMessage(maxNetworkVersion, MessagePing, 1, []byte{0, 1}))
+	f.Add(testMessage(maxNetworkVersion, MessageStore, 1, []byte{0, 0, 0, 0, 0, 0, 0, 0, 'a'}))
+	f.Add(testMessage(maxNetworkVersion, ResponseFindValue, 1, []byte{0, 1, 2}))
+	f.Add(testMessage(maxNetworkVersion, MessageFragment, 1, []byte{MessageStore, 1, 0, 0, 0, 2, 0, 0, 0, 'a'}))
+	f.Add(testMessage(200, MessageFindNode, 1, nil))
+
+	transport, error := NewMemorySwitchboard().NewTransport(&net.UDPAddr{IP:net.ParseIP("10.0.0.0"), Port:StandardPort})
+	if error != nil {
+		f.Fatal(error)
+	}
+	network := newNetwork(NewKademlia(NewRandomKademliaID()), transport)
+	f.Cleanup(func() { network.Close() })
+	sender := &net.UDPAddr{IP:net.ParseIP("10.0.0.1"), Port:StandardPort}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		//Malformed messages must be refused without a panic
+		network.handleNetworkData(sender, data)
+	})
+}
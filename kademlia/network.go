@@ -0,0 +1,632 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"log"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Wire protocol versions spoken by this node. Every version starts messages with
+//the same header and PING carries the supported versions in every version, so
+//nodes with different versions can negotiate and tell each other about them.
+//Version 0 is the format without STORE time to live, contact address families,
+//fragments and error responses, it is refused
+const minNetworkVersion = 1
+const maxNetworkVersion = 1
+const StandardPort = 20000
+const responseTimeout = 10000
+
+type NetworkResponse struct {
+	//Closed when the response has arrived
+	done chan struct{}
+
+	magicValue uint64
+	//Type of the expected response and address of the contacted node,
+	//responses of another type or from another address are dropped
+	messageType byte
+	address *net.UDPAddr
+	contacts []Contact
+	data []byte
+	//Set if the contact answered with an error response
+	error error
+}
+
+type Network struct {
+	transport Transport
+	//Requests awaiting a response, by magic value
+	responses map[uint64]*NetworkResponse
+	responsesMutex sync.Mutex
+	reassembler *reassembler
+	kademlia *Kademlia
+	//Closed by Close, requests awaiting a response fail immediately
+	closed chan struct{}
+	closeOnce sync.Once
+	//Closed when the receiving go routine has returned, nil if it was never started
+	receiverDone chan struct{}
+	//Responses sent as fragments in the background, Close waits for them
+	writers sync.WaitGroup
+	//Held while adding writers and while closing, no writers are added after Close
+	writersMutex sync.Mutex
+	//Versions spoken by this node, set before receiving messages
+	minVersion byte
+	maxVersion byte
+	//Version negotiated with each contact by address
+	versions map[string]byte
+	versionsMutex sync.Mutex
+	//Number of malformed messages received, they are dropped
+	malformedMessages atomic.Uint64
+}
+
+//NewNetwork listens for UDP messages on the listen address, for example ":20000"
+func NewNetwork(kademlia *Kademlia, listenAddress string) *Network {
+	transport, error := NewUDPTransport(listenAddress)
+	if error != nil {
+		log.Fatal(error)
+	}
+
+	return NewNetworkWithTransport(kademlia, transport)
+}
+
+//NewNetworkWithTransport sends and receives messages through the transport
+func NewNetworkWithTransport(kademlia *Kademlia, transport Transport) *Network {
+	network := newNetwork(kademlia, transport)
+	network.startReceiving()
+	return network
+}
+
+//Creates a network that does not receive messages until startReceiving is called
+func newNetwork(kademlia *Kademlia, transport Transport) *Network {
+	network := &Network{transport:transport, responses:make(map[uint64]*NetworkResponse), reassembler:newReassembler(), kademlia:kademlia, closed:make(chan struct{}), minVersion:minNetworkVersion, maxVersion:maxNetworkVersion, versions:make(map[string]byte)}
+
+	kademlia.routingTableMutex.Lock()
+	kademlia.ping = network.SendPingMessage
+	kademlia.routingTableMutex.Unlock()
+
+	return network
+}
+
+//Handles received messages in the background until the transport is closed or
+//fails, receiverDone is closed when it stops
+func (network *Network) startReceiving() {
+	network.receiverDone = make(chan struct{})
+	data := make([]byte, 65536)
+	go func() {
+		defer close(network.receiverDone)
+		for {
+			length, senderAddress, error := network.transport.ReadFrom(data)
+
+			if error != nil {
+				select {
+				case <-network.closed:
+				default:
+					log.Println(error)
+				}
+				return
+			}
+
+			if network.handleNetworkData(senderAddress, data[:length]) != nil {
+				network.malformedMessages.Add(1)
+			}
+		}
+	}()
+}
+
+//Close closes the transport and waits for the receiving go routine to return.
+//Requests awaiting a response fail, later requests fail immediately
+func (network *Network) Close() error {
+	var error error
+	network.closeOnce.Do(func() {
+		network.writersMutex.Lock()
+		close(network.closed)
+		network.writersMutex.Unlock()
+
+		//Writers stop sending fragments once closed
+		network.writers.Wait()
+		error = network.transport.Close()
+	})
+	if network.receiverDone != nil {
+		<-network.receiverDone
+	}
+	return error
+}
+
+//Handles a received message. Returns an error if the message is malformed,
+//it is then dropped unless it is a request that can be refused
+func (network *Network) handleNetworkData(senderAddress *net.UDPAddr, data []byte) error {
+	header, data, error := decodeHeader(data)
+	if error != nil {
+		return error
+	}
+
+	//Pings and error responses are understood in every version
+	supported := header.version >= network.minVersion && header.version <= network.maxVersion
+	if !supported && header.messageType != MessagePing && header.messageType != ResponsePing && header.messageType != ResponseError {
+		requestType := header.messageType
+		if header.messageType == MessageFragment && len(data) > 0 {
+			requestType = data[0]
+		}
+		if !isResponse(requestType) && requestType != MessageFragmentAck {
+			network.SendErrorResponse(senderAddress, header.version, header.magicValue, ErrorUnsupportedVersion, "supported versions are " + strconv.Itoa(int(network.minVersion)) + " to " + strconv.Itoa(int(network.maxVersion)))
+		}
+		return nil
+	}
+
+	if header.messageType == MessageFragment {
+		error = network.handleFragment(senderAddress, header.version, header.magicValue, data)
+	} else if header.messageType == MessageFragmentAck {
+		error = network.handleFragmentAck(senderAddress, header.magicValue, data)
+	} else if isResponse(header.messageType) {
+		error = network.handleNetworkDataResponse(senderAddress, header.version, header.messageType, header.magicValue, data)
+	} else {
+		error = network.handleNetworkDataRequest(senderAddress, header.version, header.messageType, header.magicValue, data)
+	}
+
+	//Add contact to routing table once its message was handled, unless it pings in a
+	//version this node does not speak
+	if error == nil && supported {
+		contact := NewContactWithPort(header.id, senderAddress.IP, senderAddress.Port)
+		network.kademlia.ContactSeen(&contact)
+	}
+	return error
+}
+
+//MalformedMessages returns the number of malformed messages received
+func (network *Network) MalformedMessages() uint64 {
+	return network.malformedMessages.Load()
+}
+
+//Remembers the highest version spoken by both this node and the contact at the address
+//that speaks the versions from min to max. Returns false if there is no such version.
+//Only versions from responses to pings sent by this node are remembered, so that
+//spoofed pings can not change the version used toward an address
+func (network *Network) negotiateVersion(address *net.UDPAddr, min byte, max byte) bool {
+	if max > network.maxVersion {
+		max = network.maxVersion
+	}
+
+	network.versionsMutex.Lock()
+	defer network.versionsMutex.Unlock()
+	if max < min || max < network.minVersion {
+		delete(network.versions, memoryAddressKey(address))
+		return false
+	}
+	network.versions[memoryAddressKey(address)] = max
+	return true
+}
+
+//Returns the version negotiated with the contact at the address, the oldest version
+//spoken by this node if none has been negotiated
+func (network *Network) versionFor(address *net.UDPAddr) byte {
+	network.versionsMutex.Lock()
+	defer network.versionsMutex.Unlock()
+	if version, ok := network.versions[memoryAddressKey(address)]; ok {
+		return version
+	}
+	return network.minVersion
+}
+
+//Completes the request awaiting the response
+func (network *Network) handleNetworkDataResponse(senderAddress *net.UDPAddr, version byte, messageType byte, magicValue uint64, data []byte) error {
+	//Decode before completing the request so that a malformed response is only dropped
+	var min, max byte
+	var remoteError *RemoteError
+	var contacts []Contact
+	var value []byte
+	var error error
+	switch messageType {
+	case ResponsePing:
+		min, max, error = decodePing(version, data)
+	case ResponseStore:
+		if len(data) != 0 {
+			error = errors.New("STORE response with data")
+		}
+	case ResponseError:
+		remoteError, error = decodeErrorResponse(data)
+	case ResponseFindNode:
+		contacts, error = dataToContacts(data)
+	case ResponseFindValue:
+		contacts, value, error = decodeFindValueResponse(data)
+	}
+	if error != nil {
+		return error
+	}
+
+	//Remove from response waiting list so the response is only completed once
+	network.responsesMutex.Lock()
+	response := network.responses[magicValue]
+	if response != nil && (memoryAddressKey(response.address) != memoryAddressKey(senderAddress) || (messageType != response.messageType && messageType != ResponseError)) {
+		network.responsesMutex.Unlock()
+		return errors.New("response does not match its request")
+	}
+	delete(network.responses, magicValue)
+	network.responsesMutex.Unlock()
+
+	if response != nil {
+		switch messageType {
+		case ResponsePing:
+			if !network.negotiateVersion(senderAddress, min, max) {
+				response.error = ErrUnsupportedVersion
+			}
+		case ResponseError:
+			response.error = remoteError
+		}
+		response.contacts = contacts
+		response.data = value
+		close(response.done)
+	}
+	return nil
+}
+
+//Handles a request, responses are sent in the version of the request.
+//Malformed requests are refused with an error response
+func (network *Network) handleNetworkDataRequest(senderAddress *net.UDPAddr, version byte, messageType byte, magicValue uint64, data []byte) error {
+	var error error
+	switch messageType {
+	case MessagePing:
+		if _, _, error = decodePing(version, data); error != nil {
+			break
+		}
+		network.SendMessageResponse(senderAddress, version, ResponsePing, magicValue, func(buffer *bytes.Buffer) {
+			buffer.WriteByte(network.minVersion)
+			buffer.WriteByte(network.maxVersion)
+		})
+	case MessageStore:
+		var ttl time.Duration
+		var value []byte
+		if ttl, value, error = decodeStore(data); error != nil {
+			break
+		}
+		if _, storeError := network.kademlia.StoreWithTTL(value, ttl); storeError != nil {
+			if code, ok := errorCodeOf(storeError); ok {
+				network.SendErrorResponse(senderAddress, version, magicValue, code, storeError.Error())
+			}
+			//Other errors are failures of this node, let the request time out
+			return nil
+		}
+		network.SendMessageResponse(senderAddress, version, ResponseStore, magicValue, func(buffer *bytes.Buffer){})
+	case MessageFindNode:
+		var target *KademliaID
+		if target, error = decodeFindNode(data); error != nil {
+			break
+		}
+		contacts := network.kademlia.LookupContact(target)
+		network.SendMessageResponse(senderAddress, version, ResponseFindNode, magicValue, func(buffer *bytes.Buffer) {
+			buffer.Write(contactsToData(contacts))
+		})
+	case MessageFindValue:
+		var hash [20]byte
+		if hash, error = decodeFindValue(data); error != nil {
+			break
+		}
+		data := network.kademlia.LookupData(hash)
+		if data == nil {
+			contacts := network.kademlia.LookupContact(NewKademliaIDFromBytes(hash[:]))
+			network.SendMessageResponse(senderAddress, version, ResponseFindValue, magicValue, func(buffer *bytes.Buffer) {
+				buffer.WriteByte(0)
+				buffer.Write(contactsToData(contacts))
+			})
+		} else {
+			network.SendMessageResponse(senderAddress, version, ResponseFindValue, magicValue, func(buffer *bytes.Buffer) {
+				buffer.WriteByte(1)
+				buffer.Write(data)
+			})
+		}
+	default:
+		error = errors.New("unknown message type " + strconv.Itoa(int(messageType)))
+	}
+
+	if error != nil {
+		network.SendErrorResponse(senderAddress, version, magicValue, ErrorMalformedRequest, error.Error())
+	}
+	return error
+}
+
+//Address family tags used in encoded contacts
+const (
+	addressFamilyIPv4 = 4
+	addressFamilyIPv6 = 6
+)
+
+//Decodes contacts encoded as ID, address family, IPv4 or IPv6 address and port
+func dataToContacts(data []byte) ([]Contact, error) {
+	const portLength = 2
+	contacts := []Contact{}
+
+	for i := 0; i < len(data); {
+		if len(data) - i < IDLength + 1 {
+			return nil, errors.New("contact with incorrect length")
+		}
+		id := NewKademliaIDFromBytes(data[i:i + IDLength])
+		i += IDLength
+
+		var ipAddressLength int
+		switch data[i] {
+		case addressFamilyIPv4:
+			ipAddressLength = net.IPv4len
+		case addressFamilyIPv6:
+			ipAddressLength = net.IPv6len
+		default:
+			return nil, errors.New("contact with unknown address family " + strconv.Itoa(int(data[i])))
+		}
+		i++
+
+		if len(data) - i < ipAddressLength + portLength {
+			return nil, errors.New("contact with incorrect length")
+		}
+		address := make(net.IP, ipAddressLength)
+		copy(address, data[i:i + ipAddressLength])
+		i += ipAddressLength
+		if ipAddressLength == net.IPv6len && address.To4() != nil {
+			return nil, errors.New("contact with IPv4 address encoded as IPv6")
+		}
+
+		port := binary.LittleEndian.Uint16(data[i:i + portLength])
+		i += portLength
+
+		contacts = append(contacts, NewContactWithPort(id, address, int(port)))
+	}
+
+	return contacts, nil
+}
+
+//Encodes contacts as ID, address family, IPv4 or IPv6 address and port
+func contactsToData(contacts []Contact) []byte {
+	buffer := new(bytes.Buffer)
+
+	for _, contact := range contacts {
+		//Copy ID
+		buffer.Write(contact.ID[:])
+
+		//Copy IP, IPv4 mapped IPv6 addresses are sent as IPv4
+		if ip := contact.Address.To4(); ip != nil {
+			buffer.WriteByte(addressFamilyIPv4)
+			buffer.Write(ip)
+		} else {
+			buffer.WriteByte(addressFamilyIPv6)
+			buffer.Write(contact.Address.To16())
+		}
+
+		//Copy port
+		binary.Write(buffer, binary.LittleEndian, uint16(contact.Port))
+	}
+
+	return buffer.Bytes()
+}
+
+func (network *Network) SendMessageResponse(address *net.UDPAddr, version byte, messageType byte, magicValue uint64, writeData func(*bytes.Buffer)) {
+	buffer := new(bytes.Buffer)
+
+	//Version
+	buffer.WriteByte(version)
+
+	//Message type
+	buffer.WriteByte(messageType)
+
+	//Magic value
+	error := binary.Write(buffer, binary.LittleEndian, magicValue)
+	if error != nil {
+		log.Fatal(error)
+	}
+
+	//My ID
+	buffer.Write(network.kademlia.myID[:])
+
+	//Write custom data
+	writeData(buffer)
+
+	//Send data
+	if buffer.Len() > maxDatagramSize {
+		//Send fragments in the background so that the receiver keeps reading
+		network.writersMutex.Lock()
+		defer network.writersMutex.Unlock()
+		select {
+		case <-network.closed:
+			return
+		default:
+		}
+		network.writers.Add(1)
+		go func() {
+			defer network.writers.Done()
+			network.writeMessage(context.Background(), buffer.Bytes(), address)
+		}()
+	} else {
+		network.writeMessage(context.Background(), buffer.Bytes(), address)
+	}
+}
+
+//SendErrorResponse answers a request with an error code and a text describing the
+//error, cut to maxErrorTextLength bytes
+func (network *Network) SendErrorResponse(address *net.UDPAddr, version byte, magicValue uint64, code ErrorCode, text string) {
+	if len(text) > maxErrorTextLength {
+		text = text[:maxErrorTextLength]
+	}
+	network.SendMessageResponse(address, version, ResponseError, magicValue, func(buffer *bytes.Buffer) {
+		buffer.WriteByte(byte(code))
+		buffer.WriteString(text)
+	})
+}
+
+//SendMessage sends a request in the version negotiated with the contact and waits for
+//the response. Returns ErrNoResponse if the contact does not answer in time and a
+//*RemoteError if it answers with an error response
+func (network *Network) SendMessage(contact *Contact, messageType byte, writeData func(*bytes.Buffer)) (*NetworkResponse, error) {
+	return network.SendMessageContext(context.Background(), contact, messageType, writeData)
+}
+
+//SendMessageContext is SendMessage that stops waiting for the response when the context is cancelled
+func (network *Network) SendMessageContext(ctx context.Context, contact *Contact, messageType byte, writeData func(*bytes.Buffer)) (*NetworkResponse, error) {
+	//Contacts without an address can not be reached
+	if contact.Address == nil {
+		return nil, ErrNoAddress
+	}
+
+	version := network.versionFor(contact.UDPAddress())
+	response, error := network.sendMessage(ctx, contact, version, messageType, writeData)
+	if messageType != MessagePing && errors.Is(error, ErrUnsupportedVersion) {
+		//The contact speaks other versions than expected, negotiate and retry
+		if network.SendPingMessageContext(ctx, contact) == nil && network.versionFor(contact.UDPAddress()) != version {
+			response, error = network.sendMessage(ctx, contact, network.versionFor(contact.UDPAddress()), messageType, writeData)
+		}
+	}
+	return response, error
+}
+
+func (network *Network) sendMessage(ctx context.Context, contact *Contact, version byte, messageType byte, writeData func(*bytes.Buffer)) (*NetworkResponse, error) {
+	buffer := new(bytes.Buffer)
+
+	//Version
+	buffer.WriteByte(version)
+
+	//Message type
+	buffer.WriteByte(messageType)
+
+	//Magic value
+	magicValueBig, error := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if error != nil {
+		log.Fatal(error)
+	}
+	magicValue := magicValueBig.Uint64()
+
+	error2 := binary.Write(buffer, binary.LittleEndian, magicValue)
+	if error2 != nil {
+		log.Fatal(error2)
+	}
+
+	//My ID
+	buffer.Write(network.kademlia.myID[:])
+
+	//Write custom data
+	writeData(buffer)
+
+	//Add to response waiting list before sending so a fast response is not missed
+	response := &NetworkResponse{magicValue:magicValue, messageType:responseTypeOf(messageType), address:contact.UDPAddress(), done:make(chan struct{})}
+	network.responsesMutex.Lock()
+	network.responses[magicValue] = response
+	network.responsesMutex.Unlock()
+
+	//Send data, fragments are no longer sent once a response such as a refusal arrives
+	writeCtx, cancelWrite := context.WithCancel(ctx)
+	defer cancelWrite()
+	go func() {
+		select {
+		case <-response.done:
+			cancelWrite()
+		case <-writeCtx.Done():
+		}
+	}()
+	network.writeMessage(writeCtx, buffer.Bytes(), contact.UDPAddress())
+
+	//Await response or timeout
+	timer := time.NewTimer(responseTimeout * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-response.done:
+		if response.error != nil {
+			return nil, response.error
+		}
+		return response, nil
+	case <-timer.C:
+		error = ErrNoResponse
+	case <-ctx.Done():
+		error = ctx.Err()
+	case <-network.closed:
+		error = net.ErrClosed
+	}
+
+	//Remove from response waiting list
+	network.responsesMutex.Lock()
+	delete(network.responses, magicValue)
+	network.responsesMutex.Unlock()
+
+	//Timeout or cancelled
+	return nil, error
+}
+
+func (network *Network) SendPingMessage(contact *Contact) error {
+	return network.SendPingMessageContext(context.Background(), contact)
+}
+
+//SendPingMessageContext pings the contact and negotiates the version spoken with it.
+//Returns ErrUnsupportedVersion if the contact answers but speaks no common version
+func (network *Network) SendPingMessageContext(ctx context.Context, contact *Contact) error {
+	_, error := network.SendMessageContext(ctx, contact, MessagePing, func(buffer *bytes.Buffer) {
+		buffer.WriteByte(network.minVersion)
+		buffer.WriteByte(network.maxVersion)
+	})
+	return error
+}
+
+func (network *Network) SendFindContactMessage(contact *Contact, id *KademliaID) ([]Contact, error) {
+	return network.SendFindContactMessageContext(context.Background(), contact, id)
+}
+
+func (network *Network) SendFindContactMessageContext(ctx context.Context, contact *Contact, id *KademliaID) ([]Contact, error) {
+	response, error := network.SendMessageContext(ctx, contact, MessageFindNode, func(buffer *bytes.Buffer) {
+		for _, b := range id {
+			buffer.WriteByte(b)
+		}
+	})
+
+	if error != nil {
+		//Fail
+		return []Contact{}, error
+	}
+	return response.contacts, nil
+}
+
+//SendFindDataMessage returns the data with the hash if the contact stores it,
+//otherwise the contacts it knows closest to the hash
+func (network *Network) SendFindDataMessage(contact *Contact, hash [20]byte) ([]Contact, []byte, error) {
+	return network.SendFindDataMessageContext(context.Background(), contact, hash)
+}
+
+func (network *Network) SendFindDataMessageContext(ctx context.Context, contact *Contact, hash [20]byte) ([]Contact, []byte, error) {
+	response, error := network.SendMessageContext(ctx, contact, MessageFindValue, func(buffer *bytes.Buffer) {
+		buffer.Write(hash[:])
+	})
+
+	if error != nil {
+		//Fail
+		return []Contact{}, []byte{}, error
+	}
+	if len(response.contacts) > 0 {
+		//Return closer contacts
+		return response.contacts, []byte{}, nil
+	}
+	//Return stored data
+	return []Contact{}, response.data, nil
+}
+
+func (network *Network) SendStoreMessage(contact *Contact, data []byte) error {
+	return network.SendStoreMessageWithTTL(contact, data, valueExpiration)
+}
+
+func (network *Network) SendStoreMessageWithTTL(contact *Contact, data []byte, ttl time.Duration) error {
+	return network.SendStoreMessageContext(context.Background(), contact, data, ttl)
+}
+
+//SendStoreMessageContext stores data that expires after ttl at the contact.
+//Returns a *RemoteError matching ErrStorageFull or ErrValueTooLarge if it refuses the data
+func (network *Network) SendStoreMessageContext(ctx context.Context, contact *Contact, data []byte, ttl time.Duration) error {
+	//Clamp before converting so that the time to live can not wrap around
+	if ttl < 0 {
+		ttl = 0
+	} else if ttl > valueExpiration {
+		ttl = valueExpiration
+	}
+	_, error := network.SendMessageContext(ctx, contact, MessageStore, func(buffer *bytes.Buffer) {
+		binary.Write(buffer, binary.LittleEndian, uint64(ttl.Milliseconds()))
+		buffer.Write(data)
+	})
+	return error
+}
@@ -0,0 +1,232 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNetwork(t *testing.T) {
+	selfID := NewKademliaID("1111111100000000000000000000000000000000")
+	self := NewContact(selfID, net.ParseIP("127.0.0.1"))
+
+	unreachable := NewContact(NewKademliaID("1111111200000000000000000000000000000000"), nil)
+
+	kademlia := NewKademlia(selfID)
+	network := NewNetwork(kademlia, ":20000")
+
+	//Ping
+	if network.SendPingMessage(&self) != nil {
+		t.Error("Failed to self ping")
+	}
+	if network.SendPingMessage(&unreachable) != ErrNoAddress {
+		t.Error("Pinged unreachable node")
+	}
+
+	//Find contact
+	findContactContacts, findContactError := network.SendFindContactMessage(&self, NewRandomKademliaID())
+	if findContactError != nil {
+		t.Error("Failed to find contacts")
+	}
+	if len(findContactContacts) != 1 {
+		t.Error("Incorrect number of contacts")
+	} else if findContactContacts[0].String() != self.String() {
+		t.Error("Incorrect contact")
+	}
+
+	//Store and find data
+	data := []byte("Hello")
+	hash := sha1.Sum(data)
+
+	//Find data before stored
+	findDataContacts, findDataData, findDataError := network.SendFindDataMessage(&self, hash)
+	if findDataError != nil {
+		t.Error("Failed to request data")
+	}
+	if len(findDataContacts) != 1 {
+		t.Error("Did not return contacts")
+	}
+	if len(findDataData) != 0 {
+		t.Error("Found non-existant data")
+	}
+
+	//Store data
+	if network.SendStoreMessage(&self, data) != nil {
+		t.Error("Failed to store")
+	}
+
+	//Find data after stored
+	findDataContacts2, findDataData2, findDataError2 := network.SendFindDataMessage(&self, hash)
+	if findDataError2 != nil {
+		t.Error("Failed to find data")
+	}
+	if len(findDataContacts2) != 0 {
+		t.Error("Returned contacts")
+	}
+	if !bytes.Equal(findDataData2, data) {
+		t.Error("Found incorrect data")
+	}
+
+	//Time to live too large to convert is limited to the value expiration
+	longLived := []byte("Long lived")
+	if network.SendStoreMessageWithTTL(&self, longLived, time.Duration(math.MaxInt64)) != nil {
+		t.Error("Failed to store with a large time to live")
+	}
+	_, expiration, _ := kademlia.storage.Get(sha1.Sum(longLived))
+	if time.Until(expiration) <= 0 || time.Until(expiration) > valueExpiration {
+		t.Error("Time to live was not limited", expiration)
+	}
+
+	//Contacts with ports
+	contacts := []Contact{NewContactWithPort(selfID, net.ParseIP("192.168.0.1"), 20001), NewContact(unreachable.ID, net.ParseIP("192.168.0.2")), NewContact(selfID, net.ParseIP("fd00::2"))}
+	decodedContacts, error := dataToContacts(contactsToData(contacts))
+	if error != nil || len(decodedContacts) != 3 || decodedContacts[0].Port != 20001 || decodedContacts[1].Port != StandardPort || !decodedContacts[1].Address.Equal(contacts[1].Address) {
+		t.Error("Contacts were not encoded correctly")
+	} else if !decodedContacts[2].Address.Equal(contacts[2].Address) || decodedContacts[2].Address.To4() != nil {
+		t.Error("IPv6 contact was not encoded correctly")
+	}
+
+	//Large data sent in fragments
+	largeData := make([]byte, 4 * 1024 * 1024)
+	rand.Read(largeData)
+	if network.SendStoreMessage(&self, largeData) != nil {
+		t.Error("Failed to store large data")
+	}
+	_, findLargeData, _ := network.SendFindDataMessage(&self, sha1.Sum(largeData))
+	if !bytes.Equal(findLargeData, largeData) {
+		t.Error("Found incorrect large data")
+	}
+
+	//Cancelled ping
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+	silent := NewContactWithPort(unreachable.ID, net.ParseIP("127.0.0.1"), StandardPort + 1)
+	start := time.Now()
+	if network.SendPingMessageContext(ctx, &silent) != context.DeadlineExceeded {
+		t.Error("Pinged unreachable node")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Ping did not honour the context deadline")
+	}
+	storeCtx, storeCancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer storeCancel()
+	start = time.Now()
+	if network.SendStoreMessageContext(storeCtx, &silent, largeData, time.Hour) != context.DeadlineExceeded {
+		t.Error("Stored at unreachable node")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Fragmented STORE did not honour the context deadline")
+	}
+
+	//IPv6
+	self6 := NewContact(selfID, net.ParseIP("::1"))
+	if network.SendPingMessage(&self6) != nil {
+		t.Error("Failed to self ping over IPv6")
+	}
+
+	//Silent contacts time out
+	fmt.Println("Waiting for timeout...")
+	if network.SendPingMessage(&silent) != ErrNoResponse {
+		t.Error("Pinged unreachable node")
+	}
+
+	//Malformed requests are answered with an error response
+	_, error = network.SendMessage(&self, MessageFindNode, func(buffer *bytes.Buffer) {
+		buffer.Write([]byte("short"))
+	})
+	remoteError, ok := error.(*RemoteError)
+	if !ok || remoteError.Code != ErrorMalformedRequest || !errors.Is(error, ErrMalformedRequest) {
+		t.Error("Malformed request was not refused", error)
+	}
+	if _, error := network.SendMessage(&self, 200, func(buffer *bytes.Buffer){}); !errors.Is(error, ErrMalformedRequest) {
+		t.Error("Unknown message type was not refused", error)
+	}
+	if errors.Is(&RemoteError{Code:200}, ErrMalformedRequest) || errors.Is(&RemoteError{Code:ErrorQuotaExceeded}, ErrValueTooLarge) {
+		t.Error("Remote error matched the error of another code")
+	}
+
+	//Close waits for fragmented responses to a requester that does not acknowledge them
+	requester, error := net.ListenUDP("udp", &net.UDPAddr{IP:net.ParseIP("127.0.0.1")})
+	if error != nil {
+		t.Fatal(error)
+	}
+	defer requester.Close()
+	largeHash := sha1.Sum(largeData)
+	requester.WriteTo(testMessage(maxNetworkVersion, MessageFindValue, 5, largeHash[:]), self.UDPAddress())
+	requester.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, error := requester.ReadFrom(make([]byte, 65536)); error != nil {
+		t.Error("No fragment was sent", error)
+	}
+	start = time.Now()
+	if network.Close() != nil || time.Since(start) > time.Second {
+		t.Error("Close did not stop the fragmented response")
+	}
+}
+
+func TestVersionNegotiation(t *testing.T) {
+	switchboard := NewMemorySwitchboard()
+
+	//Networks speaking the versions from min to max
+	newVersionNetwork := func(i int, min byte, max byte) (*Network, Contact) {
+		address := &net.UDPAddr{IP:net.ParseIP(fmt.Sprintf("10.2.0.%d", i)), Port:StandardPort}
+		transport, error := switchboard.NewTransport(address)
+		if error != nil {
+			t.Fatal(error)
+		}
+		network := newNetwork(NewKademlia(NewRandomKademliaID()), transport)
+		network.minVersion, network.maxVersion = min, max
+		network.startReceiving()
+		t.Cleanup(func() { network.Close() })
+		return network, NewContact(network.kademlia.myID, address.IP)
+	}
+	current, currentContact := newVersionNetwork(0, 1, 1)
+	old, oldContact := newVersionNetwork(1, 0, 0)
+	upgraded, upgradedContact := newVersionNetwork(2, 1, 2)
+	future, futureContact := newVersionNetwork(3, 2, 2)
+
+	//The highest common version is chosen from the response to a ping
+	if current.SendPingMessage(&upgradedContact) != nil || current.versionFor(upgradedContact.UDPAddress()) != 1 {
+		t.Error("Did not negotiate version 1 with an upgraded node")
+	}
+	if _, error := current.SendFindContactMessage(&upgradedContact, NewRandomKademliaID()); error != nil {
+		t.Error("Request in the negotiated version failed", error)
+	}
+
+	//Nodes speaking the version 0 format are refused and do not add refused contacts
+	if error := current.SendPingMessage(&oldContact); error != ErrUnsupportedVersion {
+		t.Error("Negotiated a version with a version 0 node", error)
+	}
+	if len(old.kademlia.GetContacts()) != 0 {
+		t.Error("Contact pinging in an unsupported version was added")
+	}
+
+	//Requests to nodes that dropped the version are negotiated again
+	if _, error := old.SendFindContactMessage(&upgradedContact, NewRandomKademliaID()); !errors.Is(error, ErrUnsupportedVersion) {
+		t.Error("Request in an unsupported version was not refused", error)
+	}
+	if _, error := upgraded.SendFindContactMessage(&futureContact, NewRandomKademliaID()); error != nil || upgraded.versionFor(futureContact.UDPAddress()) != 2 {
+		t.Error("Did not negotiate again after a refused request", error)
+	}
+
+	//Pings do not change the version negotiated toward their sender
+	future.transport.WriteTo(testMessage(2, MessagePing, 9, []byte{1, 1}), upgradedContact.UDPAddress())
+	time.Sleep(50 * time.Millisecond)
+	if upgraded.versionFor(futureContact.UDPAddress()) != 2 {
+		t.Error("Ping changed the negotiated version")
+	}
+
+	//Nodes without a common version answer pings but can not be used
+	if error := current.SendPingMessage(&futureContact); error != ErrUnsupportedVersion {
+		t.Error("Negotiated a version with a node without common versions", error)
+	}
+	if _, error := future.SendFindContactMessage(&currentContact, NewRandomKademliaID()); !errors.Is(error, ErrUnsupportedVersion) {
+		t.Error("Request in an unsupported version was not refused", error)
+	}
+}
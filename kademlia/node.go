@@ -0,0 +1,307 @@
+package kademlia
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var errNodeNotRunning = errors.New("node is not running")
+
+// Node definition
+// a kademlia node with its network and background tasks,
+// created with NewNode and run between Start and Stop
+type Node struct {
+	kademlia *Kademlia
+	network *Network
+
+	mutex sync.Mutex
+	//Cancelled by Stop, nil until started
+	ctx context.Context
+	cancel context.CancelFunc
+	stopped bool
+	//Background loops and lookups in progress
+	tasks sync.WaitGroup
+	//Closed when the node has stopped
+	done chan struct{}
+	stopError error
+
+	//Directory the ID, values, published hashes and contacts are kept in, empty if nothing is kept
+	dataDirectory string
+	//Contacts saved by the previous run, revalidated by PingSavedContacts
+	savedContacts []Contact
+}
+
+//NewNode creates a node listening for UDP messages on the listen address, for
+//example ":20000". With a data directory the node is opened with
+//OpenNodeWithTransport, otherwise it gets a random ID and keeps values in memory
+func NewNode(listenAddress string, dataDirectory string) (*Node, error) {
+	transport, error := NewUDPTransport(listenAddress)
+	if error != nil {
+		return nil, error
+	}
+	if dataDirectory == "" {
+		return NewNodeWithTransport(NewRandomKademliaID(), transport), nil
+	}
+
+	node, error := OpenNodeWithTransport(dataDirectory, transport)
+	if error != nil {
+		transport.Close()
+		return nil, error
+	}
+	return node, nil
+}
+
+//NewNodeWithTransport creates a node that keeps values in memory and sends and
+//receives messages through the transport
+func NewNodeWithTransport(id *KademliaID, transport Transport) *Node {
+	return newNode(NewKademlia(id), transport)
+}
+
+//OpenNodeWithTransport creates a node that keeps its ID, values, published hashes and routing table
+//in the data directory, so that a restarted node continues where it stopped.
+//The routing table and published hashes are saved periodically and on Stop
+func OpenNodeWithTransport(dataDirectory string, transport Transport) (*Node, error) {
+	if error := os.MkdirAll(dataDirectory, 0755); error != nil {
+		return nil, error
+	}
+	id, error := loadNodeID(dataDirectory)
+	if error != nil {
+		return nil, error
+	}
+	contacts, error := loadContacts(dataDirectory)
+	if error != nil {
+		return nil, error
+	}
+	published, error := loadPublished(dataDirectory)
+	if error != nil {
+		return nil, error
+	}
+	storage, error := NewDiskStorage(filepath.Join(dataDirectory, valuesDirectoryName))
+	if error != nil {
+		return nil, error
+	}
+
+	node := newNode(NewKademliaWithStorage(id, storage), transport)
+	for _, hash := range published {
+		node.kademlia.addPublished(hash)
+	}
+	node.dataDirectory = dataDirectory
+	node.savedContacts = contacts
+	return node, nil
+}
+
+func newNode(kademlia *Kademlia, transport Transport) *Node {
+	return &Node{kademlia:kademlia, network:newNetwork(kademlia, transport), done:make(chan struct{})}
+}
+
+//Start receives messages and runs the republish, replication and bucket refresh
+//loops in the background. The node is stopped when the context is cancelled.
+//A node with a data directory should then call PingSavedContacts
+func (node *Node) Start(ctx context.Context) error {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	if node.stopped {
+		return errors.New("node is stopped")
+	}
+	if node.ctx != nil {
+		return errors.New("node is already started")
+	}
+	node.ctx, node.cancel = context.WithCancel(ctx)
+
+	node.network.startReceiving()
+
+	loops := []func(context.Context, *Kademlia, *Network){republishLoop, replicationLoop, bucketRefreshLoop}
+	if node.dataDirectory != "" {
+		loops = append(loops, func(ctx context.Context, kademlia *Kademlia, network *Network) {
+			for sleepContext(ctx, contactSaveInterval) {
+				if error := node.save(); error != nil {
+					log.Println(error)
+				}
+			}
+		})
+	}
+	for _, loop := range loops {
+		node.tasks.Add(1)
+		go func(loop func(context.Context, *Kademlia, *Network)) {
+			defer node.tasks.Done()
+			loop(node.ctx, node.kademlia, node.network)
+		}(loop)
+	}
+
+	//Stop when cancelled or when the transport fails, so that Done tells the owner
+	go func() {
+		select {
+		case <-node.ctx.Done():
+		case <-node.network.receiverDone:
+		}
+		node.Stop()
+	}()
+	return nil
+}
+
+//Stop cancels the background loops and lookups in progress and waits for them,
+//closes the network and writes persistent state. Stopping a stopped node waits
+//until it has stopped and returns the same error
+func (node *Node) Stop() error {
+	node.mutex.Lock()
+	if node.stopped {
+		node.mutex.Unlock()
+		<-node.done
+		return node.stopError
+	}
+	node.stopped = true
+	if node.cancel != nil {
+		node.cancel()
+	}
+	node.mutex.Unlock()
+
+	node.tasks.Wait()
+	node.stopError = node.network.Close()
+	if error := node.flush(); error != nil && node.stopError == nil {
+		node.stopError = error
+	}
+	close(node.done)
+	return node.stopError
+}
+
+//Done returns a channel that is closed when the node has stopped
+func (node *Node) Done() <-chan struct{} {
+	return node.done
+}
+
+//Running returns true between Start and Stop
+func (node *Node) Running() bool {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	return node.ctx != nil && !node.stopped
+}
+
+//Saves the routing table and the hashes of published values to the data directory
+func (node *Node) save() error {
+	error := saveContacts(node.dataDirectory, node.kademlia)
+	if publishedError := savePublished(node.dataDirectory, node.kademlia); error == nil {
+		error = publishedError
+	}
+	return error
+}
+
+//Saves the routing table and published values and closes the storage
+func (node *Node) flush() error {
+	var error error
+	if node.dataDirectory != "" {
+		error = node.save()
+	}
+	if closeError := node.kademlia.Close(); error == nil {
+		error = closeError
+	}
+	return error
+}
+
+//Runs a task with a context that is also cancelled by Stop, which waits for the task.
+//Returns false without running the task if the node is not running
+func (node *Node) run(ctx context.Context, task func(ctx context.Context)) bool {
+	node.mutex.Lock()
+	if node.ctx == nil || node.stopped {
+		node.mutex.Unlock()
+		return false
+	}
+	node.tasks.Add(1)
+	nodeContext := node.ctx
+	node.mutex.Unlock()
+	defer node.tasks.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-nodeContext.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	task(ctx)
+	return true
+}
+
+//Ping pings the contact, returns ErrNoResponse if it does not answer
+func (node *Node) Ping(ctx context.Context, contact *Contact) error {
+	error := errNodeNotRunning
+	node.run(ctx, func(ctx context.Context) {
+		error = node.network.SendPingMessageContext(ctx, contact)
+	})
+	return error
+}
+
+//LookupContact returns the maxCount closest contacts to the target
+func (node *Node) LookupContact(ctx context.Context, target *KademliaID, maxCount int) []Contact {
+	var contacts []Contact
+	node.run(ctx, func(ctx context.Context) {
+		contacts = LookupContactContext(ctx, node.kademlia, node.network, target, maxCount)
+	})
+	return contacts
+}
+
+//LookupData returns the data with the hash, nil if it is not found. Returns an error
+//if the node is not running, the context is cancelled or no contact answered
+func (node *Node) LookupData(ctx context.Context, hash [20]byte) ([]byte, error) {
+	var data []byte
+	error := errNodeNotRunning
+	node.run(ctx, func(ctx context.Context) {
+		data, error = lookupData(ctx, node.kademlia, node.network, hash)
+	})
+	return data, error
+}
+
+//StoreData publishes data at the closest nodes and returns its hash. Returns an
+//error if the node is not running or no node stored the data
+func (node *Node) StoreData(ctx context.Context, data []byte) ([20]byte, error) {
+	var hash [20]byte
+	error := errNodeNotRunning
+	node.run(ctx, func(ctx context.Context) {
+		hash, error = StoreDataContext(ctx, node.kademlia, node.network, data, ReplicationFactor)
+	})
+	return hash, error
+}
+
+//Bootstrap looks up the ID of the node through the contacts it knows, such as
+//contacts that answered a ping, and adds the closest contacts found
+func (node *Node) Bootstrap(ctx context.Context) {
+	node.kademlia.AddContacts(node.LookupContact(ctx, node.kademlia.myID, bucketSize))
+}
+
+//PingSavedContacts pings the contacts saved by the previous run of a node with a
+//data directory, adding those that answer to the routing table. Returns the
+//number of contacts that answered, if any they can be used to Bootstrap
+func (node *Node) PingSavedContacts(ctx context.Context) int {
+	answered := 0
+	mutex := sync.Mutex{}
+	waitGroup := sync.WaitGroup{}
+	for i := range node.savedContacts {
+		waitGroup.Add(1)
+		go func(contact *Contact) {
+			defer waitGroup.Done()
+			if node.Ping(ctx, contact) == nil {
+				mutex.Lock()
+				answered++
+				mutex.Unlock()
+			}
+		}(&node.savedContacts[i])
+	}
+	waitGroup.Wait()
+	return answered
+}
+
+//Kademlia returns the routing table and storage of the node
+func (node *Node) Kademlia() *Kademlia {
+	return node.kademlia
+}
+
+//Network returns the network the node sends and receives messages through
+func (node *Node) Network() *Network {
+	return node.network
+}
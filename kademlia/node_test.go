@@ -0,0 +1,165 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/sha1"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNode(t *testing.T) {
+	nodes := newMemoryNodes(t, 10)
+
+	hash, error := nodes[9].StoreData(context.Background(), []byte("Hello"))
+	if error != nil || hash != sha1.Sum([]byte("Hello")) {
+		t.Error("Failed to store data", error)
+	}
+
+	if data, error := nodes[1].LookupData(context.Background(), hash); error != nil || string(data) != "Hello" {
+		t.Error("Failed to look up data", error)
+	}
+
+	//Stop while a lookup waits for a contact that never answers
+	node := nodes[2]
+	contact := NewContact(NewRandomKademliaID(), net.ParseIP("10.1.0.0"))
+	lookupDone := make(chan struct{})
+	go func() {
+		node.Ping(context.Background(), &contact)
+		close(lookupDone)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	if error := node.Stop(); error != nil {
+		t.Error("Failed to stop node", error)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Stop took too long")
+	}
+	select {
+	case <-lookupDone:
+	default:
+		t.Error("Stop did not wait for the lookup")
+	}
+	select {
+	case <-node.Done():
+	default:
+		t.Error("Done is not closed after Stop")
+	}
+
+	if data, error := node.LookupData(context.Background(), hash); node.Running() || data != nil || error != errNodeNotRunning {
+		t.Error("Stopped node is still running")
+	}
+	if _, error := node.StoreData(context.Background(), []byte("Hello")); error == nil {
+		t.Error("Stored data on a stopped node")
+	}
+	if node.Start(context.Background()) == nil {
+		t.Error("Restarted a stopped node")
+	}
+	if node.Stop() != nil {
+		t.Error("Stopping twice failed")
+	}
+
+	//Other nodes keep working
+	if data, _ := nodes[3].LookupData(context.Background(), hash); data == nil {
+		t.Error("Failed to look up data after another node stopped")
+	}
+
+	//Cancelling the start context stops the node
+	ctx, cancel := context.WithCancel(context.Background())
+	udpNode, error := NewNode("127.0.0.1:20003", "")
+	if error != nil {
+		t.Fatal(error)
+	}
+	if udpNode.Start(ctx) != nil || udpNode.Start(ctx) == nil {
+		t.Error("Node must start exactly once")
+	}
+	cancel()
+	select {
+	case <-udpNode.Done():
+	case <-time.After(time.Second):
+		t.Error("Cancelling the context did not stop the node")
+	}
+
+	//The socket is closed, so the address can be used again
+	udpNode, error = NewNode("127.0.0.1:20003", "")
+	if error != nil {
+		t.Error("Socket was not closed", error)
+	} else {
+		udpNode.Stop()
+	}
+
+	//A failing transport stops the node
+	failingTransport, _ := NewMemorySwitchboard().NewTransport(&net.UDPAddr{IP: net.ParseIP("10.3.0.0"), Port: StandardPort})
+	failing := NewNodeWithTransport(NewRandomKademliaID(), failingTransport)
+	failing.Start(context.Background())
+	failingTransport.Close()
+	select {
+	case <-failing.Done():
+	case <-time.After(time.Second):
+		t.Error("Node kept running after its transport failed")
+	}
+
+	//Nodes with a data directory keep their ID, values and contacts across restarts
+	switchboard := NewMemorySwitchboard()
+	persistentAddress := &net.UDPAddr{IP: net.ParseIP("10.2.0.0"), Port: StandardPort}
+	otherTransport, _ := switchboard.NewTransport(&net.UDPAddr{IP: net.ParseIP("10.2.0.1"), Port: StandardPort})
+	other := NewNodeWithTransport(NewRandomKademliaID(), otherTransport)
+	other.Start(context.Background())
+	defer other.Stop()
+
+	directory := t.TempDir()
+	transport, _ := switchboard.NewTransport(persistentAddress)
+	persistent, error := OpenNodeWithTransport(directory, transport)
+	if error != nil {
+		t.Fatal(error)
+	}
+	persistent.Start(context.Background())
+	if persistent.PingSavedContacts(context.Background()) != 0 {
+		t.Error("New node has saved contacts")
+	}
+	otherContact := NewContact(nil, net.ParseIP("10.2.0.1"))
+	if persistent.Ping(context.Background(), &otherContact) != nil {
+		t.Error("Failed to ping other node")
+	}
+	savedHash := persistent.kademlia.Store([]byte("Saved"))
+	publishedHash, error := persistent.StoreData(context.Background(), []byte("Published"))
+	if error != nil {
+		t.Error("Failed to publish data", error)
+	}
+	id := *persistent.kademlia.myID
+	if persistent.Stop() != nil {
+		t.Error("Failed to save node")
+	}
+
+	transport, _ = switchboard.NewTransport(persistentAddress)
+	persistent, error = OpenNodeWithTransport(directory, transport)
+	if error != nil {
+		t.Fatal(error)
+	}
+	persistent.Start(context.Background())
+	defer persistent.Stop()
+	if !persistent.kademlia.myID.Equals(&id) {
+		t.Error("Restarted node has a new ID")
+	}
+	if persistent.kademlia.LookupData(savedHash) == nil {
+		t.Error("Restarted node lost its values")
+	}
+	if !persistent.kademlia.getPublished()[publishedHash] || persistent.kademlia.LookupData(publishedHash) == nil {
+		t.Error("Restarted node lost its published values")
+	}
+	if len(persistent.kademlia.GetContacts()) != 0 || persistent.PingSavedContacts(context.Background()) != 1 {
+		t.Error("Saved contacts were not revalidated")
+	}
+	if contacts := persistent.kademlia.GetContacts(); len(contacts) != 1 || !contacts[0].ID.Equals(other.kademlia.myID) {
+		t.Error("Revalidated contact was not added")
+	}
+
+	//Published values are refreshed from their local copy
+	other.kademlia.storage.Delete(publishedHash)
+	republish(context.Background(), persistent.kademlia, persistent.network, publishedHash)
+	if other.kademlia.LookupData(publishedHash) == nil {
+		t.Error("Published value was not refreshed")
+	}
+}
@@ -0,0 +1,118 @@
+package kademlia
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Time between saves of the routing table of a node with a data directory
+const contactSaveInterval = 10 * time.Minute
+
+// Files in the data directory of a node
+const (
+	idFileName = "id"
+	contactsFileName = "contacts"
+	publishedFileName = "published"
+	valuesDirectoryName = "values"
+)
+
+//Returns the ID saved in the data directory, saving a random ID if there is none
+func loadNodeID(dataDirectory string) (*KademliaID, error) {
+	path := filepath.Join(dataDirectory, idFileName)
+	content, error := os.ReadFile(path)
+	if errors.Is(error, os.ErrNotExist) {
+		id := NewRandomKademliaID()
+		return id, writeFileAtomic(path, []byte(id.String() + "\n"))
+	}
+	if error != nil {
+		return nil, error
+	}
+
+	decoded, error := hex.DecodeString(strings.TrimSpace(string(content)))
+	if error != nil || len(decoded) != IDLength {
+		return nil, errors.New(path + " does not hold a node ID")
+	}
+	return NewKademliaIDFromBytes(decoded), nil
+}
+
+//Returns the contacts saved in the data directory, written by saveContacts.
+//Lines that can not be parsed are skipped
+func loadContacts(dataDirectory string) ([]Contact, error) {
+	content, error := os.ReadFile(filepath.Join(dataDirectory, contactsFileName))
+	if errors.Is(error, os.ErrNotExist) {
+		return nil, nil
+	}
+	if error != nil {
+		return nil, error
+	}
+
+	var contacts []Contact
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		decoded, error := hex.DecodeString(fields[0])
+		contact := ParseNodeAddress(fields[1])
+		if error != nil || len(decoded) != IDLength || contact == nil {
+			continue
+		}
+		contact.ID = NewKademliaIDFromBytes(decoded)
+		contacts = append(contacts, *contact)
+	}
+	return contacts, nil
+}
+
+//Saves the contacts in the routing table to the data directory, one per line as ID and address
+func saveContacts(dataDirectory string, kademlia *Kademlia) error {
+	var contacts []Contact
+	for _, contact := range kademlia.GetContacts() {
+		if !contact.ID.Equals(kademlia.myID) {
+			contacts = append(contacts, contact)
+		}
+	}
+
+	buffer := new(bytes.Buffer)
+	writeContacts(buffer, contacts)
+	return writeFileAtomic(filepath.Join(dataDirectory, contactsFileName), buffer.Bytes())
+}
+
+//Returns the hashes of the values published by the node saved in the data
+//directory, written by savePublished. Lines that can not be parsed are skipped
+func loadPublished(dataDirectory string) ([][20]byte, error) {
+	content, error := os.ReadFile(filepath.Join(dataDirectory, publishedFileName))
+	if errors.Is(error, os.ErrNotExist) {
+		return nil, nil
+	}
+	if error != nil {
+		return nil, error
+	}
+
+	var hashes [][20]byte
+	for _, line := range strings.Fields(string(content)) {
+		var hash [20]byte
+		decoded, error := hex.DecodeString(line)
+		if error != nil || len(decoded) != len(hash) {
+			continue
+		}
+		copy(hash[:], decoded)
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+//Saves the hashes of the values published by the node to the data directory, one per line
+func savePublished(dataDirectory string, kademlia *Kademlia) error {
+	buffer := new(bytes.Buffer)
+	for hash := range kademlia.getPublished() {
+		buffer.WriteString(hex.EncodeToString(hash[:]) + "\n")
+	}
+	return writeFileAtomic(filepath.Join(dataDirectory, publishedFileName), buffer.Bytes())
+}
@@ -0,0 +1,112 @@
+package kademlia
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy chooses the values removed to make room for new values
+type EvictionPolicy int
+
+const (
+	// EvictNone refuses new values when the quota is reached
+	EvictNone EvictionPolicy = iota
+	// EvictLeastRecentlyUsed removes the values stored or looked up longest ago
+	EvictLeastRecentlyUsed
+	// EvictFarthest removes the values farthest from the ID of the node, refusing
+	// new values that are farther away than every stored value
+	EvictFarthest
+)
+
+// ParseEvictionPolicy returns the policy named "none", "lru" or "farthest"
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch name {
+	case "none":
+		return EvictNone, nil
+	case "lru":
+		return EvictLeastRecentlyUsed, nil
+	case "farthest":
+		return EvictFarthest, nil
+	}
+	return EvictNone, errors.New("unknown eviction policy " + name)
+}
+
+// Quota definition
+// limits the values stored at a node, a limit of zero is unlimited
+type Quota struct {
+	MaxBytes int64
+	MaxValues int
+	Policy EvictionPolicy
+}
+
+// storageUsage definition
+// the size and last use of every stored value
+type storageUsage struct {
+	mutex sync.Mutex
+	sizes map[[20]byte]int
+	lastUsed map[[20]byte]time.Time
+	bytes int64
+}
+
+func newStorageUsage() *storageUsage {
+	return &storageUsage{sizes:make(map[[20]byte]int), lastUsed:make(map[[20]byte]time.Time)}
+}
+
+func (usage *storageUsage) add(hash [20]byte, size int) {
+	usage.mutex.Lock()
+	usage.bytes += int64(size - usage.sizes[hash])
+	usage.sizes[hash] = size
+	usage.lastUsed[hash] = time.Now()
+	usage.mutex.Unlock()
+}
+
+func (usage *storageUsage) remove(hash [20]byte) {
+	usage.mutex.Lock()
+	usage.bytes -= int64(usage.sizes[hash])
+	delete(usage.sizes, hash)
+	delete(usage.lastUsed, hash)
+	usage.mutex.Unlock()
+}
+
+//Records a lookup of a stored value
+func (usage *storageUsage) touch(hash [20]byte) {
+	usage.mutex.Lock()
+	if _, ok := usage.sizes[hash]; ok {
+		usage.lastUsed[hash] = time.Now()
+	}
+	usage.mutex.Unlock()
+}
+
+//Returns true if a new value of the size fits within the quota
+func (usage *storageUsage) fits(quota Quota, size int) bool {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+	return (quota.MaxBytes == 0 || usage.bytes + int64(size) <= quota.MaxBytes) && (quota.MaxValues == 0 || len(usage.sizes) < quota.MaxValues)
+}
+
+//Returns the stored value to remove under the policy to make room for the new hash,
+//false if no value should be removed
+func (usage *storageUsage) victim(policy EvictionPolicy, myID *KademliaID, newHash [20]byte) ([20]byte, bool) {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+
+	var victim [20]byte
+	found := false
+	switch policy {
+	case EvictLeastRecentlyUsed:
+		for hash, lastUsed := range usage.lastUsed {
+			if !found || lastUsed.Before(usage.lastUsed[victim]) {
+				victim, found = hash, true
+			}
+		}
+	case EvictFarthest:
+		farthest := NewKademliaIDFromBytes(newHash[:]).CalcDistance(myID)
+		for hash := range usage.sizes {
+			if distance := NewKademliaIDFromBytes(hash[:]).CalcDistance(myID); farthest.Less(distance) {
+				victim, found, farthest = hash, true, distance
+			}
+		}
+	}
+	return victim, found
+}
@@ -0,0 +1,103 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	if policy, error := ParseEvictionPolicy("farthest"); policy != EvictFarthest || error != nil {
+		t.Error("Could not parse eviction policy")
+	}
+	if _, error := ParseEvictionPolicy("random"); error == nil {
+		t.Error("Parsed unknown eviction policy")
+	}
+
+	//Refuse new values when full
+	NoneTest := NewKademlia(NewRandomKademliaID())
+	NoneTest.SetQuota(Quota{MaxValues:2, Policy:EvictNone})
+	NoneTest.Store([]byte("a"))
+	NoneTest.Store([]byte("b"))
+	if _, error := NoneTest.StoreWithTTL([]byte("c"), time.Hour); error != ErrStorageFull {
+		t.Error("Stored more values than the quota")
+	}
+	if _, error := NoneTest.StoreWithTTL([]byte("a"), time.Hour); error != nil {
+		t.Error("Could not refresh a stored value when full")
+	}
+
+	//Byte limit
+	BytesTest := NewKademlia(NewRandomKademliaID())
+	BytesTest.SetQuota(Quota{MaxBytes:10, Policy:EvictLeastRecentlyUsed})
+	if _, error := BytesTest.StoreWithTTL([]byte("more than ten bytes"), time.Hour); error != ErrValueTooLarge {
+		t.Error("Stored a value larger than the quota")
+	}
+	BytesTest.Store([]byte("12345"))
+	BytesTest.Store([]byte("abcde"))
+	BytesTest.Store([]byte("ABCDE"))
+	if sizes, _ := BytesTest.storage.Sizes(); BytesTest.usage.bytes != 10 || len(sizes) != 2 {
+		t.Error("Incorrect usage after eviction", BytesTest.usage.bytes)
+	}
+
+	//Least recently used values are evicted first
+	LRUTest := NewKademlia(NewRandomKademliaID())
+	LRUTest.SetQuota(Quota{MaxValues:2, Policy:EvictLeastRecentlyUsed})
+	a := LRUTest.Store([]byte("a"))
+	time.Sleep(time.Millisecond)
+	b := LRUTest.Store([]byte("b"))
+	time.Sleep(time.Millisecond)
+	LRUTest.LookupData(a)
+	time.Sleep(time.Millisecond)
+	LRUTest.Store([]byte("c"))
+	if LRUTest.LookupData(a) == nil || LRUTest.LookupData(b) != nil {
+		t.Error("Least recently used value was not evicted")
+	}
+	time.Sleep(time.Millisecond)
+	c := LRUTest.LookupData(sha1.Sum([]byte("c")))
+	time.Sleep(time.Millisecond)
+	LRUTest.StoreWithTTL([]byte("a"), time.Minute)
+	time.Sleep(time.Millisecond)
+	LRUTest.Store([]byte("d"))
+	if c == nil || LRUTest.LookupData(a) != nil {
+		t.Error("STORE that changed nothing made a value recently used")
+	}
+
+	//Values farthest from the node ID are evicted first
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	sort.Slice(values, func(i, j int) bool {
+		hashI, hashJ := sha1.Sum(values[i]), sha1.Sum(values[j])
+		return bytes.Compare(hashI[:], hashJ[:]) < 0
+	})
+	FarthestTest := NewKademlia(NewKademliaID("0000000000000000000000000000000000000000"))
+	FarthestTest.SetQuota(Quota{MaxValues:1, Policy:EvictFarthest})
+	FarthestTest.Store(values[1])
+	if _, error := FarthestTest.StoreWithTTL(values[2], time.Hour); error != ErrStorageFull {
+		t.Error("Stored a value farther away than all stored values")
+	}
+	FarthestTest.Store(values[0])
+	if FarthestTest.LookupData(sha1.Sum(values[0])) == nil || FarthestTest.LookupData(sha1.Sum(values[1])) != nil {
+		t.Error("Farthest value was not evicted")
+	}
+
+	//Expired values no longer count
+	FarthestTest.storage.Put(sha1.Sum(values[0]), values[0], time.Now().Add(-time.Second))
+	FarthestTest.removeExpiredValues()
+	if _, error := FarthestTest.StoreWithTTL(values[2], time.Hour); error != nil {
+		t.Error("Expired value still counts against the quota")
+	}
+
+	//Full nodes refuse STORE requests
+	kademlias, networks := newMemoryNetwork(t, 2)
+	kademlias[0].SetQuota(Quota{MaxValues:1})
+	contact := NewContact(kademlias[0].myID, net.ParseIP("10.0.0.0"))
+	if error := networks[1].SendStoreMessage(&contact, []byte("a")); error != nil {
+		t.Error("STORE failed", error)
+	}
+	if error := networks[1].SendStoreMessage(&contact, []byte("b")); !errors.Is(error, ErrStorageFull) {
+		t.Error("STORE was not refused by a full node", error)
+	}
+}
@@ -0,0 +1,196 @@
+package kademlia
+
+import (
+	"time"
+)
+
+const bucketSize = 20
+const replacementCacheSize = bucketSize
+const maxContactFailures = 3
+const misbehavingDuration = time.Hour
+const maxMisbehaving = 1000
+
+
+// RoutingTable definition
+// keeps a refrence contact of me, an array of buckets,
+// the number of consecutive failed RPCs per contact and
+// the contacts that have misbehaved
+type RoutingTable struct {
+	me          *KademliaID
+	buckets     [IDLength * 8]*bucket
+	failures    map[KademliaID]int
+	misbehaving map[string]time.Time
+}
+
+// NewRoutingTable returns a new instance of a RoutingTable
+func NewRoutingTable(me *KademliaID) *RoutingTable {
+	routingTable := &RoutingTable{}
+	for i := 0; i < IDLength*8; i++ {
+		routingTable.buckets[i] = newBucket()
+	}
+	routingTable.me = me
+	routingTable.failures = make(map[KademliaID]int)
+	routingTable.misbehaving = make(map[string]time.Time)
+	return routingTable
+}
+
+// AddContact add a new contact to the correct Bucket. If the Bucket is
+// full the least recently seen Contact of the Bucket is returned.
+// Misbehaving contacts are not added
+func (routingTable *RoutingTable) AddContact(contact Contact) *Contact {
+	if routingTable.IsMisbehaving(contact) {
+		return nil
+	}
+	bucketIndex := routingTable.getBucketIndex(contact.ID)
+	bucket := routingTable.buckets[bucketIndex]
+	return bucket.AddContact(contact)
+}
+
+// RemoveContact removes the contact from the correct Bucket and
+// promotes a replacement Contact in its place
+func (routingTable *RoutingTable) RemoveContact(contact Contact) bool {
+	bucketIndex := routingTable.getBucketIndex(contact.ID)
+	bucket := routingTable.buckets[bucketIndex]
+	delete(routingTable.failures, *contact.ID)
+	return bucket.RemoveContact(contact.ID)
+}
+
+// MarkContactFailed records a failed RPC to the contact and removes it
+// after maxContactFailures consecutive failures. Returns true if removed
+func (routingTable *RoutingTable) MarkContactFailed(contact Contact) bool {
+	bucketIndex := routingTable.getBucketIndex(contact.ID)
+	if !routingTable.buckets[bucketIndex].HasContact(contact.ID) {
+		return false
+	}
+
+	routingTable.failures[*contact.ID]++
+	if routingTable.failures[*contact.ID] >= maxContactFailures {
+		return routingTable.RemoveContact(contact)
+	}
+	return false
+}
+
+// MarkContactMisbehaving removes a contact that returned incorrect data
+// and keeps it from being added again with the same address for
+// misbehavingDuration. The oldest marks are dropped above maxMisbehaving
+func (routingTable *RoutingTable) MarkContactMisbehaving(contact Contact) {
+	now := time.Now()
+	for key, expiration := range routingTable.misbehaving {
+		if !now.Before(expiration) {
+			delete(routingTable.misbehaving, key)
+		}
+	}
+	for len(routingTable.misbehaving) >= maxMisbehaving {
+		oldestKey := ""
+		var oldest time.Time
+		for key, expiration := range routingTable.misbehaving {
+			if oldestKey == "" || expiration.Before(oldest) {
+				oldestKey, oldest = key, expiration
+			}
+		}
+		delete(routingTable.misbehaving, oldestKey)
+	}
+
+	routingTable.misbehaving[misbehavingKey(contact)] = now.Add(misbehavingDuration)
+	routingTable.RemoveContact(contact)
+}
+
+// IsMisbehaving returns true if the contact has been marked as misbehaving
+// and the mark has not expired
+func (routingTable *RoutingTable) IsMisbehaving(contact Contact) bool {
+	expiration, ok := routingTable.misbehaving[misbehavingKey(contact)]
+	return ok && time.Now().Before(expiration)
+}
+
+// misbehavingKey identifies a contact by both its ID and its address, so that
+// a node claiming the ID of a misbehaving contact is not refused
+func misbehavingKey(contact Contact) string {
+	return contact.ID.String() + "@" + memoryAddressKey(contact.UDPAddress())
+}
+
+// ClearContactFailures resets the failure count of a contact that answered
+func (routingTable *RoutingTable) ClearContactFailures(id *KademliaID) {
+	delete(routingTable.failures, *id)
+}
+
+// FindClosestContacts finds the count closest Contacts to the target in the RoutingTable
+func (routingTable *RoutingTable) FindClosestContacts(target *KademliaID, count int) []Contact {
+	var candidates ContactCandidates
+	bucketIndex := routingTable.getBucketIndex(target)
+	bucket := routingTable.buckets[bucketIndex]
+
+	candidates.Append(bucket.GetContactAndCalcDistance(target))
+
+	for i := 1; (bucketIndex-i >= 0 || bucketIndex+i < IDLength*8) && candidates.Len() < count; i++ {
+		if bucketIndex-i >= 0 {
+			bucket = routingTable.buckets[bucketIndex-i]
+			candidates.Append(bucket.GetContactAndCalcDistance(target))
+		}
+		if bucketIndex+i < IDLength*8 {
+			bucket = routingTable.buckets[bucketIndex+i]
+			candidates.Append(bucket.GetContactAndCalcDistance(target))
+		}
+	}
+
+	candidates.Sort()
+
+	if count > candidates.Len() {
+		count = candidates.Len()
+	}
+
+	return candidates.GetContacts(count)
+}
+
+// MarkBucketUsed records a lookup in the range of the Bucket of the target
+func (routingTable *RoutingTable) MarkBucketUsed(target *KademliaID) {
+	routingTable.buckets[routingTable.getBucketIndex(target)].lastUsed = time.Now()
+}
+
+// GetIdleBuckets returns the indices of the Buckets without a lookup
+// in their range during the idle duration. Buckets closer to me than the
+// closest populated Bucket are skipped, there are rarely nodes to find in them
+func (routingTable *RoutingTable) GetIdleBuckets(idle time.Duration) []int {
+	closestPopulated := -1
+	for i := 0; i < IDLength*8; i++ {
+		if routingTable.buckets[i].Len() > 0 {
+			closestPopulated = i
+		}
+	}
+
+	var indices []int
+	for i := 0; i <= closestPopulated; i++ {
+		if time.Since(routingTable.buckets[i].lastUsed) >= idle {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// RandomIDInBucket returns a random KademliaID within the range of the Bucket
+func (routingTable *RoutingTable) RandomIDInBucket(bucketIndex int) *KademliaID {
+	//The distance to me has its first set bit at the bucket index
+	distance := NewRandomKademliaID()
+	for i := 0; i < IDLength*8; i++ {
+		mask := byte(1) << uint8(7-i%8)
+		if i < bucketIndex {
+			distance[i/8] &^= mask
+		} else if i == bucketIndex {
+			distance[i/8] |= mask
+		}
+	}
+	return distance.CalcDistance(routingTable.me)
+}
+
+// getBucketIndex get the correct Bucket index for the KademliaID
+func (routingTable *RoutingTable) getBucketIndex(id *KademliaID) int {
+	distance := id.CalcDistance(routingTable.me)
+	for i := 0; i < IDLength; i++ {
+		for j := 0; j < 8; j++ {
+			if (distance[i]>>uint8(7-j))&0x1 != 0 {
+				return i*8 + j
+			}
+		}
+	}
+
+	return IDLength*8 - 1
+}
@@ -0,0 +1,140 @@
+package kademlia
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRoutingTable(t *testing.T) {
+	rt := NewRoutingTable(NewKademliaID("FFFFFFFF00000000000000000000000000000000"))
+
+	rt.AddContact(NewContact(NewKademliaID("FFFFFFFF00000000000000000000000000000000"), net.ParseIP("192.168.0.2")))
+	rt.AddContact(NewContact(NewKademliaID("1111111100000000000000000000000000000000"), net.ParseIP("192.168.0.3")))
+	rt.AddContact(NewContact(NewKademliaID("1111111200000000000000000000000000000000"), net.ParseIP("192.168.0.4")))
+	rt.AddContact(NewContact(NewKademliaID("1111111300000000000000000000000000000000"), net.ParseIP("192.168.0.5")))
+	rt.AddContact(NewContact(NewKademliaID("1111111400000000000000000000000000000000"), net.ParseIP("192.168.0.6")))
+	rt.AddContact(NewContact(NewKademliaID("2111111400000000000000000000000000000000"), net.ParseIP("192.168.0.7")))
+
+	contactsEmpty := rt.FindClosestContacts(NewKademliaID("2111111400000000000000000000000000000000"), 0)
+	if len(contactsEmpty) != 0 {
+		t.Error("Returned contacts when requesting zero contacts")
+	}
+
+	contacts1 := rt.FindClosestContacts(NewKademliaID("2111111400000000000000000000000000000000"), 1)
+	if contacts1[0].ID.String() != "2111111400000000000000000000000000000000" {
+		t.Error("Returned incorrect contact")
+	}
+
+	contacts2 := rt.FindClosestContacts(NewKademliaID("2111111400000000000000000000000000000000"), 2)
+	if contacts2[0].ID.String() != "2111111400000000000000000000000000000000" && contacts2[1].ID.String() != "1111111400000000000000000000000000000000" {
+		t.Error("Returned incorrect contacts")
+	}
+
+	contacts20 := rt.FindClosestContacts(NewKademliaID("2111111400000000000000000000000000000000"), 20)
+	if len(contacts20) != 6 {
+		t.Error("Incorrect length")
+	}
+
+	if rt.getBucketIndex(NewKademliaID("0000000000000000000000000000000000000000")) != 0 {
+		t.Error("Incorrect bucket")
+	}
+	if rt.getBucketIndex(NewKademliaID("AAAAAAAA00000000000000000000000000000000")) != 1 {
+		t.Error("Incorrect bucket")
+	}
+	if rt.getBucketIndex(NewKademliaID("FFFFFFFF00000000000000000000000000000000")) != 159 {
+		t.Error("Incorrect bucket")
+	}
+
+	//Replacement cache
+	full := NewRoutingTable(NewKademliaID("FFFFFFFF00000000000000000000000000000000"))
+	for i := 0; i < bucketSize; i++ {
+		full.AddContact(NewContact(NewKademliaID(fmt.Sprintf("%040x", i + 1)), net.ParseIP("192.168.0.2")))
+	}
+	replacement := NewContact(NewKademliaID(fmt.Sprintf("%040x", bucketSize + 1)), net.ParseIP("192.168.0.3"))
+	leastRecentlySeen := full.AddContact(replacement)
+	if leastRecentlySeen == nil || leastRecentlySeen.ID.String() != fmt.Sprintf("%040x", 1) {
+		t.Error("Full bucket did not return least recently seen contact")
+	}
+	if full.FindClosestContacts(replacement.ID, 1)[0].ID.Equals(replacement.ID) {
+		t.Error("Contact was added to full bucket")
+	}
+	if !full.RemoveContact(*leastRecentlySeen) {
+		t.Error("Could not remove contact")
+	}
+	if !full.FindClosestContacts(replacement.ID, 1)[0].ID.Equals(replacement.ID) {
+		t.Error("Replacement was not promoted")
+	}
+	if full.RemoveContact(*leastRecentlySeen) {
+		t.Error("Removed contact twice")
+	}
+
+	//Failures
+	failing := NewRoutingTable(NewKademliaID("FFFFFFFF00000000000000000000000000000000"))
+	failingContact := NewContact(NewKademliaID("1111111100000000000000000000000000000000"), net.ParseIP("192.168.0.2"))
+	failing.AddContact(failingContact)
+	for i := 0; i < maxContactFailures - 1; i++ {
+		if failing.MarkContactFailed(failingContact) {
+			t.Error("Contact removed before too many failures")
+		}
+	}
+	failing.ClearContactFailures(failingContact.ID)
+	for i := 0; i < maxContactFailures - 1; i++ {
+		failing.MarkContactFailed(failingContact)
+	}
+	if !failing.MarkContactFailed(failingContact) {
+		t.Error("Contact not removed after too many failures")
+	}
+	if len(failing.FindClosestContacts(failingContact.ID, 1)) != 0 {
+		t.Error("Failed contact still in routing table")
+	}
+
+	//Bucket refresh
+	for i := 0; i < IDLength*8; i++ {
+		if rt.getBucketIndex(rt.RandomIDInBucket(i)) != i {
+			t.Error("Random ID is not in bucket " + fmt.Sprint(i))
+		}
+	}
+	if len(rt.GetIdleBuckets(time.Hour)) != 0 {
+		t.Error("New buckets are idle")
+	}
+	rt.buckets[3].lastUsed = time.Now().Add(-2 * time.Hour)
+	idle := rt.GetIdleBuckets(time.Hour)
+	if len(idle) != 1 || idle[0] != 3 {
+		t.Error("Idle bucket not found")
+	}
+	rt.MarkBucketUsed(rt.RandomIDInBucket(3))
+	if len(rt.GetIdleBuckets(time.Hour)) != 0 {
+		t.Error("Used bucket is idle")
+	}
+	sparse := NewRoutingTable(NewKademliaID("FFFFFFFF00000000000000000000000000000000"))
+	sparse.AddContact(NewContact(NewKademliaID("1111111100000000000000000000000000000000"), net.ParseIP("192.168.0.3")))
+	sparse.buckets[0].lastUsed = time.Now().Add(-2 * time.Hour)
+	sparse.buckets[100].lastUsed = time.Now().Add(-2 * time.Hour)
+	if idle := sparse.GetIdleBuckets(time.Hour); len(idle) != 1 || idle[0] != 0 {
+		t.Error("Bucket closer than the closest populated bucket is idle", idle)
+	}
+
+	//Misbehaving
+	misbehaving := NewContact(NewKademliaID("2111111400000000000000000000000000000000"), net.ParseIP("192.168.0.7"))
+	rt.MarkContactMisbehaving(misbehaving)
+	rt.AddContact(misbehaving)
+	if !rt.IsMisbehaving(misbehaving) || rt.FindClosestContacts(misbehaving.ID, 1)[0].ID.Equals(misbehaving.ID) {
+		t.Error("Misbehaving contact is in routing table")
+	}
+	sameID := NewContact(misbehaving.ID, net.ParseIP("192.168.0.8"))
+	if rt.IsMisbehaving(sameID) {
+		t.Error("Contact with the ID of a misbehaving contact at another address is misbehaving")
+	}
+	rt.misbehaving[misbehavingKey(misbehaving)] = time.Now().Add(-time.Second)
+	if rt.IsMisbehaving(misbehaving) {
+		t.Error("Misbehaving mark did not expire")
+	}
+	for i := 0; i < maxMisbehaving + 10; i++ {
+		rt.MarkContactMisbehaving(NewContact(NewKademliaID(fmt.Sprintf("%040x", i + 1)), net.ParseIP("192.168.1.1")))
+	}
+	if len(rt.misbehaving) > maxMisbehaving || !rt.IsMisbehaving(NewContact(NewKademliaID(fmt.Sprintf("%040x", maxMisbehaving + 10)), net.ParseIP("192.168.1.1"))) {
+		t.Error("Misbehaving contacts were not limited", len(rt.misbehaving))
+	}
+}
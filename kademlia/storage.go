@@ -0,0 +1,94 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+)
+
+// Storage definition
+// keeps the values stored at a node with their expiration time by hash.
+// Storages are safe for concurrent use, data passed to Put and returned
+// by Get is not shared with the storage
+type Storage interface {
+	// Get returns the data and expiration time stored for the hash,
+	// the data is nil if nothing is stored
+	Get(hash [20]byte) ([]byte, time.Time, error)
+	// Put stores data that expires at the expiration time,
+	// replacing the value stored for the hash
+	Put(hash [20]byte, data []byte, expiration time.Time) error
+	// Delete removes the value stored for the hash, if any
+	Delete(hash [20]byte) error
+	// Expirations returns the expiration time of every stored value by hash
+	Expirations() (map[[20]byte]time.Time, error)
+	// Sizes returns the size of the data of every stored value by hash
+	Sizes() (map[[20]byte]int, error)
+	// Close releases the storage, it is not used afterwards
+	Close() error
+}
+
+type storedValue struct {
+	data []byte
+	expiration time.Time
+}
+
+// memoryStorage definition
+// keeps values in a map, they are lost when the node stops
+type memoryStorage struct {
+	mutex sync.RWMutex
+	values map[[20]byte]storedValue
+}
+
+// NewMemoryStorage returns a Storage that keeps values in memory
+func NewMemoryStorage() Storage {
+	return &memoryStorage{values:make(map[[20]byte]storedValue)}
+}
+
+//Returns a copy of the stored data so that callers can not change the stored value
+func (storage *memoryStorage) Get(hash [20]byte) ([]byte, time.Time, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	value, ok := storage.values[hash]
+	if !ok {
+		return nil, time.Time{}, nil
+	}
+	return append([]byte{}, value.data...), value.expiration, nil
+}
+
+func (storage *memoryStorage) Put(hash [20]byte, data []byte, expiration time.Time) error {
+	value := storedValue{data:append([]byte{}, data...), expiration:expiration}
+	storage.mutex.Lock()
+	storage.values[hash] = value
+	storage.mutex.Unlock()
+	return nil
+}
+
+func (storage *memoryStorage) Delete(hash [20]byte) error {
+	storage.mutex.Lock()
+	delete(storage.values, hash)
+	storage.mutex.Unlock()
+	return nil
+}
+
+func (storage *memoryStorage) Expirations() (map[[20]byte]time.Time, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	expirations := make(map[[20]byte]time.Time, len(storage.values))
+	for hash, value := range storage.values {
+		expirations[hash] = value.expiration
+	}
+	return expirations, nil
+}
+
+func (storage *memoryStorage) Sizes() (map[[20]byte]int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	sizes := make(map[[20]byte]int, len(storage.values))
+	for hash, value := range storage.values {
+		sizes[hash] = len(value.data)
+	}
+	return sizes, nil
+}
+
+func (storage *memoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage(t *testing.T) {
+	directory := t.TempDir()
+	disk, error := NewDiskStorage(directory)
+	if error != nil {
+		t.Fatal(error)
+	}
+
+	for name, storage := range map[string]Storage{"memory": NewMemoryStorage(), "disk": disk} {
+		hash := sha1.Sum([]byte("hej"))
+		expiration := time.Now().Add(time.Hour).Round(0)
+
+		if data, _, error := storage.Get(hash); data != nil || error != nil {
+			t.Error(name + ": found data that was not stored")
+		}
+		storage.Put(hash, []byte("hej"), expiration)
+		if data, storedExpiration, error := storage.Get(hash); !bytes.Equal(data, []byte("hej")) || !storedExpiration.Equal(expiration) || error != nil {
+			t.Error(name + ": could not get stored data")
+		}
+		if expirations, error := storage.Expirations(); len(expirations) != 1 || !expirations[hash].Equal(expiration) || error != nil {
+			t.Error(name + ": incorrect expirations")
+		}
+		if sizes, error := storage.Sizes(); len(sizes) != 1 || sizes[hash] != 3 || error != nil {
+			t.Error(name + ": incorrect sizes")
+		}
+
+		storage.Delete(hash)
+		if data, _, _ := storage.Get(hash); data != nil {
+			t.Error(name + ": found deleted data")
+		}
+		if expirations, _ := storage.Expirations(); len(expirations) != 0 {
+			t.Error(name + ": deleted data has an expiration")
+		}
+		if storage.Delete(hash) != nil {
+			t.Error(name + ": deleting missing data failed")
+		}
+	}
+
+	//Reads that miss a value being replaced do not drop it from the index
+	raceHash := sha1.Sum([]byte("race"))
+	waitGroup := sync.WaitGroup{}
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					disk.Get(raceHash)
+				}
+			}
+		}()
+	}
+	indexed := true
+	for i := 0; i < 200 && indexed; i++ {
+		disk.Delete(raceHash)
+		disk.Put(raceHash, []byte("race"), time.Now().Add(time.Hour))
+		time.Sleep(10 * time.Microsecond)
+		expirations, _ := disk.Expirations()
+		_, indexed = expirations[raceHash]
+	}
+	close(done)
+	waitGroup.Wait()
+	if !indexed {
+		t.Error("Stored value is missing from the index")
+	}
+	disk.Delete(raceHash)
+
+	//Values survive reopening, values damaged by a crash are dropped
+	hash := sha1.Sum([]byte("hej"))
+	disk.Put(hash, []byte("hej"), time.Now().Add(time.Hour))
+	damagedHash := sha1.Sum([]byte("damaged"))
+	disk.Put(damagedHash, []byte("damaged"), time.Now().Add(time.Hour))
+	os.WriteFile(disk.(*diskStorage).path(damagedHash), []byte("dam"), 0644)
+	os.WriteFile(filepath.Join(directory, "interrupted" + temporaryFileSuffix), []byte("hej"), 0644)
+	disk.Close()
+
+	reopened, error := NewDiskStorage(directory)
+	if error != nil {
+		t.Fatal(error)
+	}
+	if data, _, _ := reopened.Get(hash); !bytes.Equal(data, []byte("hej")) {
+		t.Error("Data was lost when reopening disk storage")
+	}
+	if sizes, _ := reopened.Sizes(); len(sizes) != 1 || sizes[hash] != 3 {
+		t.Error("Values were not indexed when reopening disk storage", sizes)
+	}
+	if data, _, _ := reopened.Get(damagedHash); data != nil {
+		t.Error("Returned damaged data")
+	}
+	if _, error := os.Stat(filepath.Join(directory, "interrupted" + temporaryFileSuffix)); error == nil {
+		t.Error("Interrupted write was not removed")
+	}
+
+	//Kademlia on disk storage
+	kademlia := NewKademliaWithStorage(NewRandomKademliaID(), reopened)
+	kademlia.StoreWithTTL([]byte("hallo"), time.Minute)
+	if !bytes.Equal(kademlia.LookupData(sha1.Sum([]byte("hallo"))), []byte("hallo")) || kademlia.usage.bytes != 3 + 5 {
+		t.Error("Could not find data in disk storage")
+	}
+	reopened.Put(hash, []byte("hej"), time.Now().Add(-time.Second))
+	kademlia.removeExpiredValues()
+	if data, _, _ := reopened.Get(hash); data != nil {
+		t.Error("Expired data was not removed from disk storage")
+	}
+	kademlia.Close()
+}
+
+func TestStorageConcurrency(t *testing.T) {
+	const clients = 8
+	const valuesPerClient = 25
+	nodes := newMemoryNodes(t, clients + 1)
+	server := nodes[0].kademlia
+	serverContact := NewContact(server.myID, net.ParseIP("10.0.0.0"))
+
+	value := func(client int, i int) []byte {
+		return []byte(fmt.Sprintf("value %d from client %d", i, client))
+	}
+
+	//Every client stores its values at the server while looking up the values of the
+	//others, the server sweeps and replicates meanwhile and readers change returned data
+	waitGroup := sync.WaitGroup{}
+	for client := 1; client <= clients; client++ {
+		waitGroup.Add(2)
+		go func(client int) {
+			defer waitGroup.Done()
+			for i := 0; i < valuesPerClient; i++ {
+				if nodes[client].network.SendStoreMessage(&serverContact, value(client, i)) != nil {
+					t.Error("STORE failed")
+				}
+			}
+		}(client)
+		go func(client int) {
+			defer waitGroup.Done()
+			for i := 0; i < valuesPerClient; i++ {
+				other := client % clients + 1
+				if _, data, error := nodes[client].network.SendFindDataMessage(&serverContact, sha1.Sum(value(other, i))); error != nil || (len(data) > 0 && !bytes.Equal(data, value(other, i))) {
+					t.Error("FIND_VALUE failed or returned incorrect data")
+				}
+				if data := server.LookupData(sha1.Sum(value(other, i))); data != nil {
+					data[0] = 'X'
+				}
+			}
+		}(client)
+	}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		for i := 0; i < valuesPerClient; i++ {
+			server.removeExpiredValues()
+			server.forEachStoredValue(func(hash [20]byte, value storedValue) {
+				value.data[0] = 'X'
+			})
+		}
+	}()
+	waitGroup.Wait()
+
+	for client := 1; client <= clients; client++ {
+		for i := 0; i < valuesPerClient; i++ {
+			if !bytes.Equal(server.LookupData(sha1.Sum(value(client, i))), value(client, i)) {
+				t.Error("Value was lost or changed by concurrent access")
+			}
+		}
+	}
+}
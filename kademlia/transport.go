@@ -0,0 +1,153 @@
+package kademlia
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// Transport definition
+// sends and receives datagrams to and from addresses
+type Transport interface {
+	// ReadFrom blocks until a datagram is received, copies it into
+	// the buffer and returns its length and the sender address
+	ReadFrom(buffer []byte) (int, *net.UDPAddr, error)
+	// WriteTo sends a datagram to the address
+	WriteTo(data []byte, address *net.UDPAddr) error
+	// Close stops the transport, blocked reads return an error
+	Close() error
+}
+
+// udpTransport definition
+// sends datagrams over a UDP connection
+type udpTransport struct {
+	connection *net.UDPConn
+}
+
+// NewUDPTransport returns a Transport listening on the UDP listen address
+func NewUDPTransport(listenAddress string) (Transport, error) {
+	address, error := net.ResolveUDPAddr("udp", listenAddress)
+	if error != nil {
+		return nil, error
+	}
+
+	//Listening on an unspecified IP accepts both IPv4 and IPv6
+	connection, error := net.ListenUDP("udp", address)
+	if error != nil {
+		return nil, error
+	}
+
+	//Make room for bursts of fragments, the system may limit the size
+	connection.SetReadBuffer(4 * 1024 * 1024)
+
+	return &udpTransport{connection}, nil
+}
+
+func (transport *udpTransport) ReadFrom(buffer []byte) (int, *net.UDPAddr, error) {
+	return transport.connection.ReadFromUDP(buffer)
+}
+
+func (transport *udpTransport) WriteTo(data []byte, address *net.UDPAddr) error {
+	_, error := transport.connection.WriteToUDP(data, address)
+	return error
+}
+
+func (transport *udpTransport) Close() error {
+	return transport.connection.Close()
+}
+
+// number of datagrams queued per in-memory transport before new ones are dropped
+const memoryQueueSize = 4096
+
+// MemorySwitchboard definition
+// delivers datagrams between in-memory transports by address
+type MemorySwitchboard struct {
+	mutex      sync.RWMutex
+	transports map[string]*memoryTransport
+}
+
+// NewMemorySwitchboard returns a new instance of a MemorySwitchboard
+func NewMemorySwitchboard() *MemorySwitchboard {
+	return &MemorySwitchboard{transports: make(map[string]*memoryTransport)}
+}
+
+// NewTransport returns a Transport on the switchboard bound to the address
+func (switchboard *MemorySwitchboard) NewTransport(address *net.UDPAddr) (Transport, error) {
+	key := memoryAddressKey(address)
+
+	switchboard.mutex.Lock()
+	defer switchboard.mutex.Unlock()
+	if _, exists := switchboard.transports[key]; exists {
+		return nil, errors.New("address already in use: " + key)
+	}
+
+	transport := &memoryTransport{
+		switchboard: switchboard,
+		address:     &net.UDPAddr{IP: address.IP, Port: address.Port},
+		packets:     make(chan memoryPacket, memoryQueueSize),
+		closed:      make(chan struct{}),
+	}
+	switchboard.transports[key] = transport
+	return transport, nil
+}
+
+// memoryAddressKey returns the switchboard key of an address where
+// IPv4 and IPv4 mapped IPv6 addresses are equal
+func memoryAddressKey(address *net.UDPAddr) string {
+	ip := address.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return (&net.UDPAddr{IP: ip, Port: address.Port}).String()
+}
+
+// memoryPacket definition
+// a datagram and its sender
+type memoryPacket struct {
+	data   []byte
+	sender *net.UDPAddr
+}
+
+// memoryTransport definition
+// a Transport on a MemorySwitchboard
+type memoryTransport struct {
+	switchboard *MemorySwitchboard
+	address     *net.UDPAddr
+	packets     chan memoryPacket
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+func (transport *memoryTransport) ReadFrom(buffer []byte) (int, *net.UDPAddr, error) {
+	select {
+	case packet := <-transport.packets:
+		return copy(buffer, packet.data), packet.sender, nil
+	case <-transport.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (transport *memoryTransport) WriteTo(data []byte, address *net.UDPAddr) error {
+	transport.switchboard.mutex.RLock()
+	destination := transport.switchboard.transports[memoryAddressKey(address)]
+	transport.switchboard.mutex.RUnlock()
+
+	//Like UDP, datagrams to unknown addresses or full queues are lost
+	if destination != nil {
+		select {
+		case destination.packets <- memoryPacket{append([]byte(nil), data...), transport.address}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (transport *memoryTransport) Close() error {
+	transport.closeOnce.Do(func() {
+		transport.switchboard.mutex.Lock()
+		delete(transport.switchboard.transports, memoryAddressKey(transport.address))
+		transport.switchboard.mutex.Unlock()
+		close(transport.closed)
+	})
+	return nil
+}
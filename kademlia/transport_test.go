@@ -0,0 +1,134 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMemoryTransport(t *testing.T) {
+	switchboard := NewMemorySwitchboard()
+
+	addressA := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: StandardPort}
+	addressB := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: StandardPort}
+	transportA, _ := switchboard.NewTransport(addressA)
+	transportB, _ := switchboard.NewTransport(addressB)
+
+	if _, error := switchboard.NewTransport(addressA); error == nil {
+		t.Error("Bound the same address twice")
+	}
+
+	transportA.WriteTo([]byte("hej"), addressB)
+	buffer := make([]byte, 16)
+	length, sender, error := transportB.ReadFrom(buffer)
+	if error != nil || !bytes.Equal(buffer[:length], []byte("hej")) {
+		t.Error("Datagram was not delivered")
+	} else if sender.String() != addressA.String() {
+		t.Error("Incorrect sender (" + sender.String() + ")")
+	}
+
+	transportB.Close()
+	if _, _, error := transportB.ReadFrom(buffer); error == nil {
+		t.Error("Read from closed transport")
+	}
+	if error := transportA.WriteTo([]byte("hej"), addressB); error != nil {
+		t.Error("Datagram to closed transport was not dropped")
+	}
+}
+
+// newMemoryNodes returns nodeCount started and bootstrapped nodes on a MemorySwitchboard,
+// they are stopped when the test finishes
+func newMemoryNodes(t *testing.T, nodeCount int) []*Node {
+	switchboard := NewMemorySwitchboard()
+
+	nodes := make([]*Node, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		address := &net.UDPAddr{IP: net.ParseIP(fmt.Sprintf("10.0.%d.%d", i / 256, i % 256)), Port: StandardPort}
+		transport, error := switchboard.NewTransport(address)
+		if error != nil {
+			t.Fatal(error)
+		}
+		nodes[i] = NewNodeWithTransport(NewRandomKademliaID(), transport)
+		if error := nodes[i].Start(context.Background()); error != nil {
+			t.Fatal(error)
+		}
+		t.Cleanup(func(node *Node) func() {
+			return func() { node.Stop() }
+		}(nodes[i]))
+	}
+
+	//Bootstrap every node from the first node
+	first := NewContact(nil, net.ParseIP("10.0.0.0"))
+	for i := 1; i < nodeCount; i++ {
+		if nodes[i].network.SendPingMessage(&first) != nil {
+			t.Fatal("Failed to ping first node")
+		}
+		nodes[i].kademlia.AddContacts(LookupContact(nodes[i].kademlia, nodes[i].network, nodes[i].kademlia.myID, bucketSize))
+	}
+
+	return nodes
+}
+
+// newMemoryNetwork returns the Kademlia and Network of nodeCount nodes from newMemoryNodes
+func newMemoryNetwork(t *testing.T, nodeCount int) ([]*Kademlia, []*Network) {
+	nodes := newMemoryNodes(t, nodeCount)
+
+	kademlias := make([]*Kademlia, nodeCount)
+	networks := make([]*Network, nodeCount)
+	for i, node := range nodes {
+		kademlias[i] = node.kademlia
+		networks[i] = node.network
+	}
+	return kademlias, networks
+}
+
+func TestMemoryNetwork(t *testing.T) {
+	const nodeCount = 100
+	kademlias, networks := newMemoryNetwork(t, nodeCount)
+
+	data := []byte("Hello")
+	hash := StoreData(kademlias[nodeCount - 1], networks[nodeCount - 1], data, ReplicationFactor)
+
+	if !bytes.Equal(LookupData(kademlias[1], networks[1], hash), data) {
+		t.Error("Could not find data stored in memory network")
+	}
+
+	largeData := bytes.Repeat([]byte("Hello"), 1024 * 1024)
+	largeHash := StoreData(kademlias[3], networks[3], largeData, ReplicationFactor)
+	if !bytes.Equal(LookupData(kademlias[4], networks[4], largeHash), largeData) {
+		t.Error("Could not find large data stored in memory network")
+	}
+
+	//Nodes returning data that does not match the hash
+	badHash := StoreData(kademlias[5], networks[5], []byte("Good"), ReplicationFactor)
+	for _, kademlia := range kademlias {
+		kademlia.storageMutex.Lock()
+		if storedData, _, _ := kademlia.storage.Get(badHash); storedData != nil {
+			kademlia.storage.Put(badHash, []byte("Bad"), time.Now().Add(time.Hour))
+		}
+		kademlia.storageMutex.Unlock()
+	}
+	requesterIndex := 0
+	for kademlias[requesterIndex].LookupData(badHash) != nil {
+		requesterIndex++
+	}
+	requester := kademlias[requesterIndex]
+	if LookupData(requester, networks[requesterIndex], badHash) != nil {
+		t.Error("Returned data that does not match the hash")
+	}
+	requester.routingTableMutex.RLock()
+	if len(requester.routing_table.misbehaving) == 0 {
+		t.Error("Misbehaving contact was not recorded")
+	}
+	requester.routingTableMutex.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if LookupDataContext(ctx, kademlias[2], networks[2], sha1.Sum([]byte("Missing"))) != nil {
+		t.Error("Cancelled lookup returned data")
+	}
+}
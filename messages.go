@@ -1,12 +0,0 @@
-package main
-
-const (
-	MessagePing = 0
-	MessageStore = 1
-	MessageFindNode = 2
-	MessageFindValue = 3
-	ResponsePing = 4
-	ResponseStore = 5
-	ResponseFindNode = 6
-	ResponseFindValue = 7
-)
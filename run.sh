@@ -1 +1 @@
-go run .
\ No newline at end of file
+go run ./cmd/kademliad
\ No newline at end of file
@@ -1,2 +1,2 @@
-go test -cover
+go test -cover ./...
 pause
\ No newline at end of file